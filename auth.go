@@ -0,0 +1,99 @@
+package ccpsecrets
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	// authMethodMTLS authenticates using a client certificate
+	authMethodMTLS = "mtls"
+	// authMethodNone does not authenticate the client, CCP identifies the
+	// application by its application ID and allowed machines only
+	authMethodNone = "none"
+)
+
+// authStrategy authenticates the CCP client against the CCP Web Service
+type authStrategy interface {
+	// validate checks that the config contains the settings required by the
+	// strategy
+	validate(c *clientConfig) error
+	// clientCertificate returns the client certificate presented during the
+	// TLS handshake
+	clientCertificate(c *clientConfig) (*tls.Certificate, error)
+}
+
+// authStrategies contains the supported auth methods
+var authStrategies = map[string]authStrategy{
+	authMethodMTLS: mtlsAuth{},
+	authMethodNone: noAuth{},
+}
+
+// authMethods returns the names of the supported auth methods
+func authMethods() []string {
+	methods := make([]string, 0, len(authStrategies))
+	for method := range authStrategies {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// authStrategy returns the auth strategy of the config. If no auth method is
+// set, mtls is used if a client certificate is configured, otherwise none.
+func (c *clientConfig) authStrategy() (authStrategy, error) {
+	method := c.AuthMethod
+	if len(method) == 0 {
+		method = authMethodNone
+		if len(c.ClientCert) != 0 || len(c.ClientKey) != 0 || c.CertSource == certSourceIssuer {
+			method = authMethodMTLS
+		}
+	}
+
+	strategy, ok := authStrategies[method]
+	if !ok {
+		return nil, fmt.Errorf("invalid auth_method %q: use one of %v", method, authMethods())
+	}
+	return strategy, nil
+}
+
+// mtlsAuth authenticates using mutual TLS
+type mtlsAuth struct{}
+
+func (mtlsAuth) validate(c *clientConfig) error {
+	if c.CertSource == certSourceIssuer {
+		return nil
+	}
+	if len(c.ClientCert) == 0 || len(c.ClientKey) == 0 {
+		return errors.New("both client_cert and client_key must be provided")
+	}
+	return nil
+}
+
+func (mtlsAuth) clientCertificate(c *clientConfig) (*tls.Certificate, error) {
+	if len(c.ClientCert) == 0 || len(c.ClientKey) == 0 {
+		return nil, errors.New("both client_cert and client_key must be provided")
+	}
+
+	cert, err := tls.X509KeyPair(c.ClientCert, c.ClientKey)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// noAuth does not present a client certificate
+type noAuth struct{}
+
+func (noAuth) validate(c *clientConfig) error {
+	if len(c.ClientCert) != 0 || len(c.ClientKey) != 0 || c.CertSource == certSourceIssuer {
+		return fmt.Errorf("a client certificate can not be used with auth_method %s", authMethodNone)
+	}
+	return nil
+}
+
+func (noAuth) clientCertificate(c *clientConfig) (*tls.Certificate, error) {
+	return &tls.Certificate{}, nil
+}
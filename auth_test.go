@@ -0,0 +1,34 @@
+package ccpsecrets
+
+import "testing"
+
+func TestAuthStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		config clientConfig
+		want   authStrategy
+		valid  bool
+	}{
+		{config: clientConfig{}, want: noAuth{}, valid: true},
+		{config: clientConfig{ClientCert: []byte("cert"), ClientKey: []byte("key")}, want: mtlsAuth{}, valid: true},
+		{config: clientConfig{CertSource: certSourceIssuer}, want: mtlsAuth{}, valid: true},
+		{config: clientConfig{AuthMethod: authMethodMTLS}, want: mtlsAuth{}, valid: false},
+		{config: clientConfig{AuthMethod: authMethodNone, ClientCert: []byte("cert")}, want: noAuth{}, valid: false},
+	} {
+		strategy, err := tc.config.authStrategy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strategy != tc.want {
+			t.Errorf("%+v: got %T, want %T", tc.config, strategy, tc.want)
+		}
+		if err := strategy.validate(&tc.config); (err == nil) != tc.valid {
+			t.Errorf("%+v: got validation error %v", tc.config, err)
+		}
+	}
+
+	for _, method := range []string{"basic", "kerberos"} {
+		if _, err := (&clientConfig{AuthMethod: method}).authStrategy(); err == nil {
+			t.Errorf("%s: expected an error for an unsupported auth_method", method)
+		}
+	}
+}
@@ -6,19 +6,42 @@ import (
 	"sync"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/salt"
 	"github.com/hashicorp/vault/sdk/logical"
-	ccp "github.com/liviusnl/go-ccp"
 )
 
 const configPath string = "config"
 const objectPath string = "object"
 const queryPath string = "query"
+const rolesPath string = "roles"
+const credsPath string = "creds"
+const cachePath string = "cache"
+const csrPath string = "csr"
+const issuerPath string = "issuer"
+const inventoryPath string = "inventory"
+const batchPath string = "batch"
+const templatesPath string = "templates"
+const statusPath string = "status"
+
+// operationPrefixCCP prefixes the OpenAPI operation IDs of the paths
+const operationPrefixCCP string = "ccp"
+
+// defaultConnection is the connection used when a request does not select one
+const defaultConnection string = "default"
 
 type backend struct {
 	*framework.Backend
 
-	lock   sync.Mutex
-	client *ccp.Client
+	lock        sync.Mutex
+	connections map[string]*ccpConnection
+
+	// configLock serializes updates to the stored connections
+	configLock sync.Mutex
+
+	cache *responseCache
+
+	saltLock sync.RWMutex
+	salt     *salt.Salt
 }
 
 // Factory returns a new backend as logical.Backend.
@@ -32,31 +55,55 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 
 // Backend implements the CCP Secrets Engine.
 func newBackend() *backend {
-	var b = &backend{}
+	var b = &backend{
+		connections: make(map[string]*ccpConnection),
+		cache:       newResponseCache(),
+	}
 
 	b.Backend = &framework.Backend{
 		BackendType: logical.TypeLogical,
 		Help:        strings.TrimSpace(backendHelp),
 		PathsSpecial: &logical.Paths{
+			Root: []string{
+				configPath + "/+/pem",
+			},
 			LocalStorage: []string{
 				framework.WALPrefix,
+				cachePath + "/",
 			},
 			SealWrapStorage: []string{
 				configPath,
+				configPath + "/",
+				cachePath + "/",
+				csrPath + "/",
+				issuerPath + "/",
 			},
 		},
 
 		Paths: framework.PathAppend(
+			pathConfig(b),
+			pathRoles(b),
+			pathInventory(b),
+			pathTemplates(b),
 			[]*framework.Path{
-				pathConfig(b),
+				pathObjectList(b),
 				pathObject(b),
 				pathQuery(b),
+				pathCreds(b),
+				pathBatch(b),
+				pathStatus(b),
 			},
 		),
 
+		Secrets: []*framework.Secret{
+			secretCredential(b),
+		},
+
 		InitializeFunc: b.initialize,
 		Invalidate:     b.invalidate,
 
+		PeriodicFunc: b.reissueCertificates,
+
 		Clean: b.cleanup,
 	}
 
@@ -65,22 +112,85 @@ func newBackend() *backend {
 
 // initialize the plugin.
 func (b *backend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
-	b.Client(ctx, req.Storage)
+	if err := b.migrateConfig(ctx, req.Storage); err != nil {
+		b.Logger().Warn("unable to migrate the legacy config to a named connection", "error", err)
+	}
 
 	return nil
 }
 
+// migrateConfig moves a config stored by a previous version of the plugin
+// under the config key to the default connection.
+func (b *backend) migrateConfig(ctx context.Context, s logical.Storage) error {
+	entry, err := s.Get(ctx, configPath)
+	if err != nil || entry == nil {
+		return err
+	}
+
+	existing, err := s.Get(ctx, connectionKey(defaultConnection))
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		entry.Key = connectionKey(defaultConnection)
+		if err := s.Put(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	return s.Delete(ctx, configPath)
+}
+
 // invalidate resets the plugin. This is called when a key is updated via
 // replication.
 func (b *backend) invalidate(ctx context.Context, key string) {
-	switch key {
-	case configPath:
-		b.ResetClient(nil)
+	switch {
+	case strings.HasPrefix(key, configPath+"/"):
+		name := strings.TrimPrefix(key, configPath+"/")
+		b.ResetConnection(name, nil)
+		b.cache.purge(name)
+	case strings.HasPrefix(key, issuerPath+"/"):
+		b.ResetConnection(strings.TrimPrefix(key, issuerPath+"/"), nil)
+	case key == salt.DefaultLocation:
+		b.saltLock.Lock()
+		b.salt = nil
+		b.saltLock.Unlock()
+	}
+}
+
+// Salt returns the salt of the mount, which is created on first use
+func (b *backend) Salt(ctx context.Context, s logical.Storage) (*salt.Salt, error) {
+	b.saltLock.RLock()
+	if b.salt != nil {
+		defer b.saltLock.RUnlock()
+		return b.salt, nil
 	}
+	b.saltLock.RUnlock()
+
+	b.saltLock.Lock()
+	defer b.saltLock.Unlock()
+	if b.salt != nil {
+		return b.salt, nil
+	}
+	salt, err := salt.NewSalt(ctx, s, &salt.Config{
+		HashFunc: salt.SHA256Hash,
+		Location: salt.DefaultLocation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.salt = salt
+	return salt, nil
 }
 
 func (b *backend) cleanup(ctx context.Context) {
-	b.ResetClient(nil)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for name, conn := range b.connections {
+		conn.close()
+		delete(b.connections, name)
+	}
 }
 
 const backendHelp = `
@@ -88,6 +198,7 @@ The CyberArk Credentials Provider (CCP) allows users to access secrets stored
 in the CyberArk Enterprise Password Vault (EPV) without the need to directly 
 access the CCP Web Service.
 
-After mounting this secrets engine, you can configure the secrets eingine
-using the "config/" endpoints.
+After mounting this secrets engine, you can configure one or more connections
+to CCP Web Services using the "config/<name>" endpoints. Requests use the
+"default" connection, unless another connection is selected.
 `
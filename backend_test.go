@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"testing"
 
 	logicaltest "github.com/hashicorp/vault/helper/testhelpers/logical"
@@ -32,23 +33,69 @@ func TestBackend(t *testing.T) {
 	logicaltest.Test(t, logicaltest.TestCase{
 		LogicalBackend: b,
 		Steps: []logicaltest.TestStep{
-			testAccStepConfigWrite(t, ts, "MyApp"),
-			testAccStepConfigRead(t, ts, "MyApp"),
+			testAccStepConfigWrite(t, ts, "default", "MyApp"),
+			testAccStepConfigRead(t, ts, "default", "MyApp"),
+			testAccStepConfigPEM(t, ts, "default"),
 			testAccStepObject(t, "/MySafe/MyObject"),
-			testAccStepConfigWrite(t, ts, "OtherApp"),
-			testAccStepConfigRead(t, ts, "OtherApp"),
+			testAccStepConfigWrite(t, ts, "default", "OtherApp"),
+			testAccStepConfigRead(t, ts, "default", "OtherApp"),
 			testAccStepObject(t, "/MySafe/MyFolder/MyObject"),
 			testAccStepQuery(t, "MyUser"),
+			testAccStepConfigVerify(t, "default"),
+			testAccStepConfigRotateCert(t, ts, "default", "OtherApp"),
+			testAccStepObject(t, "/MySafe/MyObject"),
+			testAccStepConfigRollbackCert(t, "default"),
+			testAccStepObject(t, "/MySafe/MyObject"),
+			testAccStepConfigWrite(t, ts, "other", "MyApp"),
+			testAccStepConfigList(t, "default", "other"),
+			testAccStepObjectConnection(t, "other", "/MySafe/MyObject"),
+			testAccStepConfigDelete(t, "other"),
+			testAccStepConfigList(t, "default"),
+			testAccStepRoleWrite(t, "my-role", map[string]interface{}{
+				"safes":   "MySafe",
+				"folders": "MyFolder",
+				"objects": "MyObject",
+			}),
+			testAccStepCreds(t, "my-role"),
 		},
 	})
 
 }
 
-func testAccStepConfigWrite(t *testing.T, ts *ccptest.Server, applicationID string) logicaltest.TestStep {
+// testBackend returns a backend with in memory storage, for tests not
+// requiring a CCP Web Service
+func testBackend(t *testing.T) (logical.Backend, logical.Storage) {
+	t.Helper()
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, config.StorageView
+}
+
+// testRequest executes a request against the backend and fails the test on
+// an error response
+func testRequest(t *testing.T, b logical.Backend, s logical.Storage, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: op,
+		Path:      path,
+		Data:      data,
+		Storage:   s,
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("%s %s: %v %v", op, path, err, resp)
+	}
+	return resp
+}
+
+func testAccStepConfigWrite(t *testing.T, ts *ccptest.Server, name, applicationID string) logicaltest.TestStep {
 	clientCert, clientKey := ts.ClientCertificate(applicationID)
 	return logicaltest.TestStep{
 		Operation: logical.UpdateOperation,
-		Path:      "config",
+		Path:      "config/" + name,
 		Data: map[string]interface{}{
 			"host":           ts.Host,
 			"application_id": applicationID,
@@ -59,14 +106,15 @@ func testAccStepConfigWrite(t *testing.T, ts *ccptest.Server, applicationID stri
 	}
 }
 
-func testAccStepConfigRead(t *testing.T, ts *ccptest.Server, applicationID string) logicaltest.TestStep {
+func testAccStepConfigRead(t *testing.T, ts *ccptest.Server, name, applicationID string) logicaltest.TestStep {
 	return logicaltest.TestStep{
 		Operation: logical.ReadOperation,
-		Path:      "config",
+		Path:      "config/" + name,
 		Check: func(resp *logical.Response) error {
 			var d struct {
-				ApplicationID string `mapstructure:"application_id"`
-				RootCA        string `mapstructure:"root_ca"`
+				ApplicationID string                   `mapstructure:"application_id"`
+				RootCA        string                   `mapstructure:"root_ca"`
+				RootCAInfo    []map[string]interface{} `mapstructure:"root_ca_info"`
 			}
 			if err := mapstructure.Decode(resp.Data, &d); err != nil {
 				return err
@@ -76,6 +124,31 @@ func testAccStepConfigRead(t *testing.T, ts *ccptest.Server, applicationID strin
 				return fmt.Errorf("got %v: want %v", d.ApplicationID, applicationID)
 			}
 
+			if len(d.RootCA) != 0 {
+				return fmt.Errorf("got root_ca %v: want no PEM", d.RootCA)
+			}
+
+			if len(d.RootCAInfo) == 0 || d.RootCAInfo[0]["fingerprint_sha256"] == "" {
+				return fmt.Errorf("got %v: want root_ca fingerprint", d.RootCAInfo)
+			}
+
+			return nil
+		},
+	}
+}
+
+func testAccStepConfigPEM(t *testing.T, ts *ccptest.Server, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "config/" + name + "/pem",
+		Check: func(resp *logical.Response) error {
+			var d struct {
+				RootCA string `mapstructure:"root_ca"`
+			}
+			if err := mapstructure.Decode(resp.Data, &d); err != nil {
+				return err
+			}
+
 			if !bytes.Equal([]byte(d.RootCA), ts.ServerRootCA()) {
 				return fmt.Errorf("got %v: want %v", d.RootCA, ts.ServerRootCA())
 			}
@@ -85,12 +158,82 @@ func testAccStepConfigRead(t *testing.T, ts *ccptest.Server, applicationID strin
 	}
 }
 
+func testAccStepConfigList(t *testing.T, names ...string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ListOperation,
+		Path:      "config",
+		Check: func(resp *logical.Response) error {
+			var d struct {
+				Keys []string `mapstructure:"keys"`
+			}
+			if err := mapstructure.Decode(resp.Data, &d); err != nil {
+				return err
+			}
+
+			if !reflect.DeepEqual(d.Keys, names) {
+				return fmt.Errorf("got %v: want %v", d.Keys, names)
+			}
+
+			return nil
+		},
+	}
+}
+
+func testAccStepConfigDelete(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.DeleteOperation,
+		Path:      "config/" + name,
+	}
+}
+
+func testAccStepConfigVerify(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "config/" + name + "/verify",
+		Data: map[string]interface{}{
+			"verify_safe":   "MySafe",
+			"verify_object": "MyObject",
+		},
+		Check: func(resp *logical.Response) error {
+			if verified, _ := resp.Data["verified"].(bool); !verified {
+				return fmt.Errorf("connection not verified: %v", resp.Data["hosts"])
+			}
+
+			return nil
+		},
+	}
+}
+
+func testAccStepConfigRotateCert(t *testing.T, ts *ccptest.Server, name, applicationID string) logicaltest.TestStep {
+	clientCert, clientKey := ts.ClientCertificate(applicationID)
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config/" + name + "/rotate-cert",
+		Data: map[string]interface{}{
+			"client_cert": clientCert,
+			"client_key":  clientKey,
+		},
+	}
+}
+
+func testAccStepConfigRollbackCert(t *testing.T, name string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "config/" + name + "/rollback-cert",
+	}
+}
+
 func testAccStepObject(t *testing.T, request string) logicaltest.TestStep {
+	return testAccStepObjectConnection(t, "default", request)
+}
+
+func testAccStepObjectConnection(t *testing.T, connection, request string) logicaltest.TestStep {
 	return logicaltest.TestStep{
 		Operation: logical.ReadOperation,
 		Path:      "object" + request,
 		Data: map[string]interface{}{
-			"username": request,
+			"username":   request,
+			"connection": connection,
 		},
 		Check: func(resp *logical.Response) error {
 			var d struct {
@@ -109,6 +252,38 @@ func testAccStepObject(t *testing.T, request string) logicaltest.TestStep {
 	}
 }
 
+func testAccStepRoleWrite(t *testing.T, name string, data map[string]interface{}) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/" + name,
+		Data:      data,
+	}
+}
+
+func testAccStepCreds(t *testing.T, role string) logicaltest.TestStep {
+	return logicaltest.TestStep{
+		Operation: logical.ReadOperation,
+		Path:      "creds/" + role,
+		Check: func(resp *logical.Response) error {
+			if resp.Secret == nil || resp.Secret.InternalData["object"] != "MyObject" {
+				return fmt.Errorf("expected a lease for MyObject, got %v", resp.Secret)
+			}
+
+			var d struct {
+				Content string `mapstructure:"content"`
+			}
+			if err := mapstructure.Decode(resp.Data, &d); err != nil {
+				return err
+			}
+			if len(d.Content) == 0 {
+				return fmt.Errorf("Error retrieving content")
+			}
+
+			return nil
+		},
+	}
+}
+
 func testAccStepQuery(t *testing.T, request string) logicaltest.TestStep {
 	return logicaltest.TestStep{
 		Operation: logical.ReadOperation,
@@ -0,0 +1,132 @@
+package ccpsecrets
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerClosed passes requests to the CCP Web Service
+	breakerClosed = "closed"
+	// breakerOpen fails requests without contacting the CCP Web Service
+	breakerOpen = "open"
+	// breakerHalfOpen passes a single probe request to the CCP Web Service
+	breakerHalfOpen = "half_open"
+)
+
+// errBreakerOpen is returned for requests rejected by an open circuit breaker
+var errBreakerOpen = errors.New("circuit breaker open")
+
+// circuitBreaker stops sending requests to the CCP Web Service of a connection
+// after consecutive transport failures. After the timeout, a single probe
+// request is passed. The breaker closes if the probe succeeds and opens again
+// if it fails.
+type circuitBreaker struct {
+	// The number of consecutive failures opening the breaker. If zero, the
+	// breaker never opens.
+	threshold int
+	timeout   time.Duration
+
+	lock     sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// newCircuitBreaker returns the circuit breaker of the config
+func newCircuitBreaker(c *clientConfig) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: c.BreakerThreshold,
+		timeout:   time.Duration(c.BreakerTimeout) * time.Second,
+	}
+}
+
+// state returns the state of the breaker
+func (cb *circuitBreaker) state(now time.Time) string {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	return cb.stateLocked(now)
+}
+
+func (cb *circuitBreaker) stateLocked(now time.Time) string {
+	switch {
+	case cb.openedAt.IsZero():
+		return breakerClosed
+	case now.Before(cb.openedAt.Add(cb.timeout)):
+		return breakerOpen
+	default:
+		return breakerHalfOpen
+	}
+}
+
+// allow returns an error if the request must not be sent. In the half open
+// state, only the first request is allowed as probe.
+func (cb *circuitBreaker) allow(now time.Time) error {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch cb.stateLocked(now) {
+	case breakerClosed:
+		return nil
+	case breakerHalfOpen:
+		if !cb.probing {
+			cb.probing = true
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the CCP Web Service failed %d consecutive times, retrying after %s",
+		errBreakerOpen, cb.failures, cb.openedAt.Add(cb.timeout).UTC().Format(time.RFC3339))
+}
+
+// succeeded closes the breaker
+func (cb *circuitBreaker) succeeded() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.failures = 0
+	cb.openedAt = time.Time{}
+	cb.probing = false
+}
+
+// failed counts a transport failure. The breaker opens when the threshold is
+// reached, or when the probe failed.
+func (cb *circuitBreaker) failed(now time.Time) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.failures++
+	if cb.threshold > 0 && (cb.probing || cb.failures >= cb.threshold) {
+		cb.openedAt = now
+	}
+	cb.probing = false
+}
+
+// release ends a probe which was neither successful nor failed, e.g. because
+// the request was canceled
+func (cb *circuitBreaker) release() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.probing = false
+}
+
+// status returns the state of the breaker as response data
+func (cb *circuitBreaker) status(now time.Time) map[string]interface{} {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	status := map[string]interface{}{
+		"state":                cb.stateLocked(now),
+		"consecutive_failures": cb.failures,
+		"opened_at":            "",
+		"half_open_at":         "",
+	}
+	if !cb.openedAt.IsZero() {
+		status["opened_at"] = cb.openedAt.UTC().Format(time.RFC3339)
+		status["half_open_at"] = cb.openedAt.Add(cb.timeout).UTC().Format(time.RFC3339)
+	}
+	return status
+}
@@ -0,0 +1,83 @@
+package ccpsecrets
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := newCircuitBreaker(&clientConfig{BreakerThreshold: 2, BreakerTimeout: 30})
+	now := time.Now()
+
+	cb.failed(now)
+	if got := cb.state(now); got != breakerClosed {
+		t.Fatalf("got %s after 1 failure: want closed", got)
+	}
+	cb.failed(now)
+	if got := cb.state(now); got != breakerOpen {
+		t.Fatalf("got %s after 2 failures: want open", got)
+	}
+	if err := cb.allow(now); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("got %v: want the request rejected", err)
+	}
+
+	// After the timeout a single probe is allowed, a failed probe opens the
+	// breaker again
+	later := now.Add(30 * time.Second)
+	if err := cb.allow(later); err != nil {
+		t.Fatalf("got %v: want the probe allowed", err)
+	}
+	if err := cb.allow(later); err == nil {
+		t.Fatal("expected a second probe to be rejected")
+	}
+	cb.failed(later)
+	if got := cb.state(later); got != breakerOpen {
+		t.Fatalf("got %s after a failed probe: want open", got)
+	}
+
+	// A successful probe closes the breaker
+	later = later.Add(30 * time.Second)
+	if err := cb.allow(later); err != nil {
+		t.Fatalf("got %v: want the probe allowed", err)
+	}
+	cb.succeeded()
+	if got := cb.state(later); got != breakerClosed {
+		t.Fatalf("got %s after a successful probe: want closed", got)
+	}
+
+	// Without a threshold the breaker never opens
+	cb = newCircuitBreaker(&clientConfig{})
+	for i := 0; i < 10; i++ {
+		cb.failed(now)
+	}
+	if err := cb.allow(now); err != nil {
+		t.Fatalf("got %v: want the request allowed", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	b, s := testBackend(t)
+
+	testRequest(t, b, s, logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":              "ccp1.example.com",
+		"application_id":    "MyApp",
+		"breaker_threshold": 3,
+		"verify_connection": false,
+	})
+
+	resp := testRequest(t, b, s, logical.ReadOperation, "status/default", nil)
+	if got := resp.Data["state"]; got != breakerClosed {
+		t.Fatalf("got state %v: want closed", got)
+	}
+	if got := resp.Data["healthy_hosts"].([]string); len(got) != 1 {
+		t.Fatalf("got healthy_hosts %v: want 1 host", got)
+	}
+
+	resp = testRequest(t, b, s, logical.ReadOperation, "config/default", nil)
+	if got := resp.Data["breaker_threshold"]; got != 3 {
+		t.Fatalf("got breaker_threshold %v: want 3", got)
+	}
+}
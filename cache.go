@@ -0,0 +1,192 @@
+package ccpsecrets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// cacheEntry is a response of the CCP Web Service kept in the response cache
+type cacheEntry struct {
+	// The response data of a successful request
+	Data map[string]interface{} `json:"data"`
+	// The logical error of a failed request
+	LogicalError string `json:"logical_error"`
+	// The time the response was retrieved from the CCP Web Service
+	Retrieved time.Time `json:"retrieved"`
+	// The time after which the response must be retrieved again
+	Expires time.Time `json:"expires"`
+	// The time after which the response is removed from the cache. Until
+	// then, the response is served stale if the CCP Web Service is unavailable.
+	Evicts time.Time `json:"evicts"`
+}
+
+// maxCacheEntries is the number of responses cached in memory per connection.
+// If the limit is reached, the response evicted first is removed.
+const maxCacheEntries = 1000
+
+// responseCache caches the responses of the CCP Web Service by connection
+type responseCache struct {
+	lock    sync.RWMutex
+	entries map[string]map[string]*cacheEntry
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[string]map[string]*cacheEntry),
+	}
+}
+
+// cacheKey returns the key identifying the credential request in the cache.
+// The reason is part of the key, so every reason is recorded by CCP.
+func cacheKey(cr *credentialRequest) (string, error) {
+	b, err := json.Marshal(cr)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cacheStorageKey returns the storage key of a cached response
+func cacheStorageKey(connection, key string) string {
+	return cachePath + "/" + connection + "/" + key
+}
+
+// get returns the cached response, or nil if the response is not cached. If
+// storage is set, a response not found in memory is looked up in storage.
+// Evicted responses are removed from memory and storage.
+func (c *responseCache) get(ctx context.Context, s logical.Storage, connection, key string) (*cacheEntry, error) {
+	now := time.Now()
+	c.lock.RLock()
+	e, ok := c.entries[connection][key]
+	c.lock.RUnlock()
+	if ok && now.Before(e.Evicts) {
+		return e, nil
+	}
+	if ok {
+		c.lock.Lock()
+		if c.entries[connection][key] == e {
+			delete(c.entries[connection], key)
+		}
+		c.lock.Unlock()
+	}
+	if s == nil {
+		return nil, nil
+	}
+
+	se, err := s.Get(ctx, cacheStorageKey(connection, key))
+	if err != nil || se == nil {
+		return nil, err
+	}
+
+	e = &cacheEntry{}
+	if err := se.DecodeJSON(e); err != nil {
+		return nil, err
+	}
+	// The JSON encoding does not keep the types of the response fields
+	if e.Data != nil {
+		e.Data = normalizeResponse(e.Data)
+	}
+	if !now.Before(e.Evicts) {
+		return nil, s.Delete(ctx, cacheStorageKey(connection, key))
+	}
+
+	c.lock.Lock()
+	evicted := c.set(connection, key, e, now)
+	c.lock.Unlock()
+	return e, deleteStored(ctx, s, connection, evicted)
+}
+
+// put adds the response to the cache. If storage is set, the response is also
+// stored and the responses evicted from memory are removed from storage.
+func (c *responseCache) put(ctx context.Context, s logical.Storage, connection, key string, e *cacheEntry) error {
+	c.lock.Lock()
+	evicted := c.set(connection, key, e, time.Now())
+	c.lock.Unlock()
+
+	if s == nil {
+		return nil
+	}
+
+	se, err := logical.StorageEntryJSON(cacheStorageKey(connection, key), e)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, se); err != nil {
+		return err
+	}
+	return deleteStored(ctx, s, connection, evicted)
+}
+
+// set adds the response to the cache in memory and returns the keys of the
+// evicted responses. If the connection has maxCacheEntries responses, the
+// evicted responses are removed first, then the response evicted first. The
+// caller must hold the lock.
+func (c *responseCache) set(connection, key string, e *cacheEntry, now time.Time) []string {
+	entries := c.entries[connection]
+	if entries == nil {
+		entries = make(map[string]*cacheEntry)
+		c.entries[connection] = entries
+	}
+
+	var evicted []string
+	if _, ok := entries[key]; !ok && len(entries) >= maxCacheEntries {
+		var first string
+		for k, ce := range entries {
+			if !now.Before(ce.Evicts) {
+				delete(entries, k)
+				evicted = append(evicted, k)
+			} else if len(first) == 0 || ce.Evicts.Before(entries[first].Evicts) {
+				first = k
+			}
+		}
+		if len(entries) >= maxCacheEntries {
+			delete(entries, first)
+			evicted = append(evicted, first)
+		}
+	}
+	entries[key] = e
+	return evicted
+}
+
+// deleteStored removes the cached responses from storage
+func deleteStored(ctx context.Context, s logical.Storage, connection string, keys []string) error {
+	for _, key := range keys {
+		if err := s.Delete(ctx, cacheStorageKey(connection, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purge removes the cached responses of the connection from memory.
+func (c *responseCache) purge(connection string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.entries, connection)
+}
+
+// purgeStorage removes the cached responses of the connection from memory and
+// storage.
+func (c *responseCache) purgeStorage(ctx context.Context, s logical.Storage, connection string) error {
+	c.purge(connection)
+
+	keys, err := s.List(ctx, cachePath+"/"+connection+"/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.Delete(ctx, cacheStorageKey(connection, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package ccpsecrets
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	ccp "github.com/liviusnl/go-ccp"
+)
+
+func TestResponseCache(t *testing.T) {
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	c := newResponseCache()
+
+	key, err := cacheKey(&credentialRequest{Connection: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &cacheEntry{
+		Data:      normalizeResponse(map[string]interface{}{"content": "secret", "retries_count": "2"}),
+		Retrieved: time.Now(),
+		Expires:   time.Now().Add(time.Minute),
+		Evicts:    time.Now().Add(time.Minute),
+	}
+	if err := c.put(ctx, s, "default", key, e); err != nil {
+		t.Fatal(err)
+	}
+
+	// Served from memory
+	got, err := c.get(ctx, nil, "default", key)
+	if err != nil || got == nil || got.Data["content"] != "secret" {
+		t.Fatalf("got %v, %v: want cached entry", got, err)
+	}
+
+	// Served from storage after the memory is purged
+	c.purge("default")
+	got, err = c.get(ctx, s, "default", key)
+	if err != nil || got == nil || got.Data["content"] != "secret" {
+		t.Fatalf("got %v, %v: want stored entry", got, err)
+	}
+	if got.Data["retries_count"] != 2 {
+		t.Fatalf("got %T: want the stored field normalized", got.Data["retries_count"])
+	}
+
+	if err := c.purgeStorage(ctx, s, "default"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = c.get(ctx, s, "default", key)
+	if err != nil || got != nil {
+		t.Fatalf("got %v, %v: want no entry", got, err)
+	}
+
+	// An evicted response is removed from memory and storage
+	e.Evicts = time.Now().Add(-time.Second)
+	if err := c.put(ctx, s, "default", key, e); err != nil {
+		t.Fatal(err)
+	}
+	got, err = c.get(ctx, s, "default", key)
+	if err != nil || got != nil {
+		t.Fatalf("got %v, %v: want no entry", got, err)
+	}
+	if se, err := s.Get(ctx, cacheStorageKey("default", key)); err != nil || se != nil {
+		t.Fatalf("got %v, %v: want the stored entry removed", se, err)
+	}
+}
+
+func TestResponseCacheLimit(t *testing.T) {
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	c := newResponseCache()
+	now := time.Now()
+
+	for i := 0; i < maxCacheEntries+1; i++ {
+		e := &cacheEntry{
+			Expires: now.Add(time.Minute),
+			Evicts:  now.Add(time.Duration(i+1) * time.Minute),
+		}
+		if err := c.put(ctx, s, "default", fmt.Sprint(i), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The response evicted first is removed from memory and storage
+	if got := len(c.entries["default"]); got != maxCacheEntries {
+		t.Fatalf("got %d entries: want %d", got, maxCacheEntries)
+	}
+	if got, err := c.get(ctx, s, "default", "0"); err != nil || got != nil {
+		t.Fatalf("got %v, %v: want the first entry evicted", got, err)
+	}
+	if got, err := c.get(ctx, s, "default", fmt.Sprint(maxCacheEntries)); err != nil || got == nil {
+		t.Fatalf("got %v, %v: want the last entry cached", got, err)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	cr := &credentialRequest{Connection: "default", Request: ccp.PasswordRequest{Object: "MyObject", Reason: "deploy"}}
+	key, err := cacheKey(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := *cr
+	other.Request.Reason = "backup"
+	if got, _ := cacheKey(&other); got == key {
+		t.Fatal("expected the reason to be part of the key")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	config := &clientConfig{CacheTTL: 60, NegativeCacheTTL: 10}
+	for _, tt := range []struct {
+		cresp *credentialResponse
+		want  time.Duration
+	}{
+		{&credentialResponse{Data: map[string]interface{}{"content": "secret"}}, time.Minute},
+		{&credentialResponse{LogicalError: "APPAP004E Password object matching query was not found"}, 10 * time.Second},
+		{&credentialResponse{Data: map[string]interface{}{"content": "old", "password_change_in_process": true}}, 0},
+		{&credentialResponse{LogicalError: "APPAP282E Password object is being reset by the CPM"}, 0},
+	} {
+		if got := config.cacheTTL(tt.cresp); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.cresp, got, tt.want)
+		}
+	}
+}
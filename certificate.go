@@ -0,0 +1,108 @@
+package ccpsecrets
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseCertificates parses the PEM encoded certificates
+func parseCertificates(b []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return certs, nil
+}
+
+// fingerprint returns the colon separated SHA-256 fingerprint of the
+// certificate
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
+
+// certificateInfo returns the details of the certificate, without the
+// certificate itself
+func certificateInfo(cert *x509.Certificate) map[string]interface{} {
+	return map[string]interface{}{
+		"fingerprint_sha256": fingerprint(cert),
+		"subject":            cert.Subject.String(),
+		"issuer":             cert.Issuer.String(),
+		"serial_number":      cert.SerialNumber.String(),
+		"not_before":         cert.NotBefore.UTC().Format(time.RFC3339),
+		"not_after":          cert.NotAfter.UTC().Format(time.RFC3339),
+	}
+}
+
+// certificatesInfo returns the details of the PEM encoded certificates. If
+// no certificates are provided, nil is returned.
+func certificatesInfo(b []byte) ([]map[string]interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	certs, err := parseCertificates(b)
+	if err != nil {
+		return nil, err
+	}
+
+	info := make([]map[string]interface{}, 0, len(certs))
+	for _, cert := range certs {
+		info = append(info, certificateInfo(cert))
+	}
+	return info, nil
+}
+
+// clientCertExpiry returns the expiry of the client certificate of the config,
+// or the zero time if the config has no client certificate.
+func (c *clientConfig) clientCertExpiry() (time.Time, error) {
+	if len(c.ClientCert) == 0 {
+		return time.Time{}, nil
+	}
+
+	certs, err := parseCertificates(c.ClientCert)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return certs[0].NotAfter, nil
+}
+
+// certExpiryWarning returns a warning if the certificate has expired or
+// expires within the window, otherwise an empty string is returned.
+func certExpiryWarning(expiry time.Time, window time.Duration, now time.Time) string {
+	switch {
+	case expiry.IsZero():
+		return ""
+	case !now.Before(expiry):
+		return fmt.Sprintf("the client certificate expired at %s", expiry.UTC().Format(time.RFC3339))
+	case now.Add(window).After(expiry):
+		return fmt.Sprintf("the client certificate expires at %s", expiry.UTC().Format(time.RFC3339))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,27 @@
+package ccpsecrets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCertExpiryWarning(t *testing.T) {
+	now := time.Now()
+	window := 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		expiry  time.Time
+		warning bool
+	}{
+		{"no certificate", time.Time{}, false},
+		{"valid", now.Add(48 * time.Hour), false},
+		{"within window", now.Add(time.Hour), true},
+		{"expired", now.Add(-time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := certExpiryWarning(tt.expiry, window, now); (len(got) != 0) != tt.warning {
+			t.Errorf("%s: got %q: want warning %v", tt.name, got, tt.warning)
+		}
+	}
+}
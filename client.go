@@ -5,6 +5,9 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
 	ccp "github.com/liviusnl/go-ccp"
@@ -12,10 +15,42 @@ import (
 
 // ccpConfig containt the configuration for the CCP client
 type clientConfig struct {
-	// The hostname of CCP Web Service host.
-	// This should be a hostname with an optipnal port number.
+	// The hostname of CCP Web Service host, as stored by previous versions
+	// of the plugin. Replaced by Hosts.
+	Host string `json:"host,omitempty" mapstructure:"-"`
+	// The hostnames of CCP Web Service hosts.
+	// These should be hostnames with an optipnal port number.
 	// Using the format: hostname[:port]
-	Host string `json:"host" mapstructure:"host"`
+	Hosts []string `json:"hosts" mapstructure:"-"`
+	// How a host is selected from Hosts: failover or round_robin
+	HostSelection string `json:"host_selection" mapstructure:"host_selection"`
+	// The number of seconds an unhealthy host is skipped after its first
+	// failure. The back-off doubles with every consecutive failure, up to
+	// HostMaxBackoff.
+	HostBackoff    int `json:"host_backoff" mapstructure:"host_backoff"`
+	HostMaxBackoff int `json:"host_max_backoff" mapstructure:"host_max_backoff"`
+	// The number of times a request failing with a transport error or one
+	// of RetryErrorCodes is retried. The number of seconds between retries
+	// starts at MinBackoff and doubles with every retry, up to MaxBackoff.
+	// Jitter randomizes the back-off.
+	MaxRetries      int      `json:"max_retries" mapstructure:"max_retries"`
+	MinBackoff      int      `json:"min_backoff" mapstructure:"min_backoff"`
+	MaxBackoff      int      `json:"max_backoff" mapstructure:"max_backoff"`
+	Jitter          bool     `json:"jitter" mapstructure:"jitter"`
+	RetryErrorCodes []string `json:"retry_error_codes" mapstructure:"-"`
+	// The number of consecutive transport failures opening the circuit
+	// breaker of the connection, and the number of seconds the breaker stays
+	// open before a probe request is passed. If BreakerThreshold is zero,
+	// the breaker never opens.
+	BreakerThreshold int `json:"breaker_threshold" mapstructure:"breaker_threshold"`
+	BreakerTimeout   int `json:"breaker_timeout" mapstructure:"breaker_timeout"`
+	// The number of seconds a request waits for a password change in
+	// progress to complete, polling CCP every PasswordChangePollInterval
+	// seconds. If zero, the response is returned immediately. A request
+	// can override the wait up to MaxPasswordChangeWait seconds.
+	PasswordChangeWait         int `json:"password_change_wait" mapstructure:"password_change_wait"`
+	PasswordChangePollInterval int `json:"password_change_poll_interval" mapstructure:"password_change_poll_interval"`
+	MaxPasswordChangeWait      int `json:"max_password_change_wait" mapstructure:"max_password_change_wait"`
 	// The ID of the application performaing the password request
 	ApplicationID string `json:"application_id" mapstructure:"application_id"`
 	// The number of seconds that the Central Credential Provider
@@ -41,36 +76,97 @@ type clientConfig struct {
 	// RootCAs is a PEM encoded certificate or bundle to verify the
 	// CCP Web Service Server Certificate
 	RootCA []byte `json:"root_ca" mapstructure:"root_ca"`
+	// The number of seconds responses are cached. If zero, responses are
+	// not cached.
+	CacheTTL int `json:"cache_ttl" mapstructure:"cache_ttl"`
+	// The number of seconds CCP logical errors are cached. If zero, logical
+	// errors are not cached.
+	NegativeCacheTTL int `json:"negative_cache_ttl" mapstructure:"negative_cache_ttl"`
+	// The number of seconds an expired response is served, when the CCP Web
+	// Service is unreachable. Expired responses are not refreshed in the
+	// background, only when requested.
+	CacheStaleIfErrorTTL int `json:"cache_stale_if_error_ttl" mapstructure:"cache_stale_if_error_ttl"`
+	// Whether or not cached responses are kept in seal wrapped storage, in
+	// addition to memory.
+	CacheStorage bool `json:"cache_storage" mapstructure:"cache_storage"`
+	// The default and maximum number of seconds of the lease of a retrieved
+	// secret. If zero, the system defaults of the mount are used.
+	TTL    int `json:"ttl" mapstructure:"ttl"`
+	MaxTTL int `json:"max_ttl" mapstructure:"max_ttl"`
+	// The method used to authenticate against the CCP Web Service: mtls or
+	// none. If empty, mtls is used when a client certificate is configured.
+	AuthMethod string `json:"auth_method" mapstructure:"auth_method"`
+	// The source of the client certificate: static or issuer
+	CertSource string `json:"cert_source" mapstructure:"cert_source"`
+	// The object requested to verify the connection. If no object is set,
+	// only the TLS connection is verified.
+	VerifySafe   string `json:"verify_safe" mapstructure:"verify_safe"`
+	VerifyFolder string `json:"verify_folder" mapstructure:"verify_folder"`
+	VerifyObject string `json:"verify_object" mapstructure:"verify_object"`
+	// The client certificate and key staged by a certificate rotation, which
+	// are promoted after verification.
+	StagedClientCert []byte `json:"staged_client_cert,omitempty" mapstructure:"-"`
+	StagedClientKey  []byte `json:"staged_client_key,omitempty" mapstructure:"-"`
+	// The client certificate and key replaced by the last certificate
+	// rotation, which are restored by a rollback.
+	PreviousClientCert []byte `json:"previous_client_cert,omitempty" mapstructure:"-"`
+	PreviousClientKey  []byte `json:"previous_client_key,omitempty" mapstructure:"-"`
+	// The number of seconds before the expiry of the client certificate,
+	// warnings are added to responses.
+	CertExpiryWarningWindow int `json:"cert_expiry_warning_window" mapstructure:"cert_expiry_warning_window"`
 }
 
-// Create a new CCP Client
-func createClient(c *clientConfig) (*ccp.Client, error) {
-	var cert tls.Certificate
-	switch {
-	case len(c.ClientCert) != 0 && len(c.ClientKey) != 0:
-		var err error
-		cert, err = tls.X509KeyPair(c.ClientCert, c.ClientKey)
-		if err != nil {
-			return nil, err
-		}
-	case len(c.ClientCert) != 0 || len(c.ClientKey) != 0:
-		return nil, errors.New("both client_cert and client_key must be provided")
+// certificates parses the client certificate and the root CAs of the config
+func (c *clientConfig) certificates() (*tls.Certificate, *x509.CertPool, error) {
+	strategy, err := c.authStrategy()
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := strategy.clientCertificate(c)
+	if err != nil {
+		return nil, nil, err
 	}
+
 	var rootCAs *x509.CertPool
 	if len(c.RootCA) != 0 {
 		rootCAs = x509.NewCertPool()
 		ok := rootCAs.AppendCertsFromPEM(c.RootCA)
 		if !ok {
-			return nil, errors.New("unable to parse the certificate(s) in root_ca")
+			return nil, nil, errors.New("unable to parse the certificate(s) in root_ca")
 		}
 	}
+	return cert, rootCAs, nil
+}
+
+// errUnknownConnection is returned for requests using a connection without
+// config
+var errUnknownConnection = errors.New("unknown connection")
+
+// errConnectionPending is returned for requests using a connection which waits
+// for the signed certificate of its CSR
+var errConnectionPending = errors.New("the connection is pending a signed client certificate")
+
+// pendingSignedCert returns true if the config uses mtls without a client
+// certificate. The connection can not be used until the signed certificate of
+// its CSR is set using set-signed-cert.
+func (c *clientConfig) pendingSignedCert() bool {
+	return c.AuthMethod == authMethodMTLS && c.CertSource == certSourceStatic &&
+		len(c.ClientCert) == 0 && len(c.ClientKey) == 0
+}
+
+// Create a new CCP Client for the host
+func createClient(c *clientConfig, host string) (*ccp.Client, error) {
+	cert, rootCAs, err := c.certificates()
+	if err != nil {
+		return nil, err
+	}
 
 	client, err := ccp.NewClient(&ccp.Config{
-		Host:                        c.Host,
+		Host:                        host,
 		ApplicationID:               c.ApplicationID,
 		ConnectionTimeout:           c.ConnectionTimeout,
 		FailRequestOnPasswordChange: c.FailRequestOnPasswordChange,
-		ClientCertificate:           &cert,
+		ClientCertificate:           cert,
 		SkipTLSVerify:               c.SkipTLSVerify,
 		EnableTLSRenegotiation:      c.EnableTLSRenegotiation,
 		RootCAs:                     rootCAs,
@@ -81,42 +177,224 @@ func createClient(c *clientConfig) (*ccp.Client, error) {
 	return client, nil
 }
 
-// Client returns the CCP Client.
-func (b *backend) Client(ctx context.Context, s logical.Storage) (*ccp.Client, error) {
-	b.lock.Lock()
-	defer b.lock.Unlock()
-
-	if b.client != nil {
-		return b.client, nil
-	}
+// connectionKey returns the storage key of the named connection.
+func connectionKey(name string) string {
+	return configPath + "/" + name
+}
 
-	entry, err := s.Get(ctx, configPath)
+// getConfig returns the config of the named connection, or nil if the
+// connection does not exist.
+func getConfig(ctx context.Context, s logical.Storage, name string) (*clientConfig, error) {
+	entry, err := s.Get(ctx, connectionKey(name))
 	if err != nil {
 		return nil, err
 	}
 	if entry == nil {
-		return nil, errors.New("configure the CCP client with config first")
+		return nil, nil
 	}
 
 	config := &clientConfig{}
 	if err := entry.DecodeJSON(config); err != nil {
 		return nil, err
 	}
+	if len(config.Hosts) == 0 && len(config.Host) != 0 {
+		config.Hosts = []string{config.Host}
+		config.Host = ""
+	}
+	if len(config.HostSelection) == 0 {
+		config.HostSelection = hostSelectionFailover
+	}
+	if len(config.CertSource) == 0 {
+		config.CertSource = certSourceStatic
+	}
+	if config.PasswordChangePollInterval == 0 {
+		config.PasswordChangePollInterval = defaultPasswordChangePollInterval
+	}
+	return config, nil
+}
+
+// putConfig stores the config of the named connection.
+func putConfig(ctx context.Context, s logical.Storage, name string, config *clientConfig) error {
+	entry, err := logical.StorageEntryJSON(connectionKey(name), config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// ccpConnection holds the config and the clients of a named connection
+type ccpConnection struct {
+	config     *clientConfig
+	hosts      []*ccpHost
+	breaker    *circuitBreaker
+	certExpiry time.Time
+	// The time the issued client certificate must be reissued, or the zero
+	// time if the client certificate is not issued
+	reissueAt time.Time
+	// Whether a request is reissuing the client certificate. Guarded by the
+	// lock of the backend, as reissueAt.
+	reissuing bool
+
+	lock sync.Mutex
+	next int
+}
+
+// newConnection creates a client for every host of the config
+func newConnection(config *clientConfig) (*ccpConnection, error) {
+	if len(config.Hosts) == 0 {
+		return nil, errors.New("no host provided")
+	}
 
-	client, err := createClient(config)
+	certExpiry, err := config.clientCertExpiry()
+	if err != nil {
+		return nil, err
+	}
 
-	b.client = client
-	return client, err
+	conn := &ccpConnection{
+		config:     config,
+		breaker:    newCircuitBreaker(config),
+		certExpiry: certExpiry,
+	}
+	for _, host := range config.Hosts {
+		client, err := createClient(config, host)
+		if err != nil {
+			conn.close()
+			return nil, err
+		}
+		conn.hosts = append(conn.hosts, &ccpHost{
+			host:   host,
+			client: client,
+		})
+	}
+	return conn, nil
 }
 
-// ResetClient forces a new client next time Client() is called.
-func (b *backend) ResetClient(newClient *ccp.Client) {
+// certExpiryWarning returns a warning if the client certificate of the
+// connection expires within the warning window.
+func (c *ccpConnection) certExpiryWarning(now time.Time) string {
+	window := time.Duration(c.config.CertExpiryWarningWindow) * time.Second
+	return certExpiryWarning(c.certExpiry, window, now)
+}
+
+// needsReissue returns true if the issued client certificate of the
+// connection must be reissued.
+func (c *ccpConnection) needsReissue(now time.Time) bool {
+	return !c.reissueAt.IsZero() && !now.Before(c.reissueAt)
+}
+
+// close closes the clients of the connection
+func (c *ccpConnection) close() {
+	for _, h := range c.hosts {
+		h.client.Close()
+	}
+}
+
+// Connection returns the named connection. A connection whose client
+// certificate must be reissued is rebuilt by the first request, other requests
+// use the connection meanwhile.
+func (b *backend) Connection(ctx context.Context, s logical.Storage, name string) (*ccpConnection, error) {
+	b.lock.Lock()
+	existing, ok := b.connections[name]
+	if !ok {
+		defer b.lock.Unlock()
+		return b.createConnection(ctx, s, name)
+	}
+	if existing.reissuing || !existing.needsReissue(time.Now()) {
+		b.lock.Unlock()
+		return existing, nil
+	}
+	existing.reissuing = true
+	b.lock.Unlock()
+
+	return b.reissueConnection(ctx, s, name, existing)
+}
+
+// createConnection creates the named connection. The caller must hold the
+// lock.
+func (b *backend) createConnection(ctx context.Context, s logical.Storage, name string) (*ccpConnection, error) {
+	config, err := getConfig(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("%w: configure the CCP client with config/%s first", errUnknownConnection, name)
+	}
+
+	conn, err := buildConnection(ctx, s, name, config)
+	if err != nil {
+		return nil, err
+	}
+	if warning := conn.certExpiryWarning(time.Now()); len(warning) != 0 {
+		b.Logger().Warn(warning, "connection", name)
+	}
+	b.connections[name] = conn
+	return conn, nil
+}
+
+// reissueConnection rebuilds the connection to reissue its client
+// certificate, without holding the lock while the certificate is issued. If
+// the reissue fails, the current connection is used while its certificate is
+// valid and the reissue is retried after reissueRetryInterval.
+func (b *backend) reissueConnection(ctx context.Context, s logical.Storage, name string, existing *ccpConnection) (*ccpConnection, error) {
+	config, err := getConfig(ctx, s, name)
+	if err == nil && config == nil {
+		err = fmt.Errorf("%w: configure the CCP client with config/%s first", errUnknownConnection, name)
+	}
+	var conn *ccpConnection
+	if err == nil {
+		conn, err = buildConnection(ctx, s, name, config)
+	}
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
+	existing.reissuing = false
 
-	if b.client != nil {
-		b.client.Close()
+	if current, ok := b.connections[name]; !ok || current != existing {
+		// The connection was reset during the reissue
+		if conn != nil {
+			conn.close()
+		}
+		if ok {
+			return current, nil
+		}
+		return b.createConnection(ctx, s, name)
 	}
 
-	b.client = newClient
+	now := time.Now()
+	if err != nil {
+		if now.Before(existing.certExpiry) {
+			existing.reissueAt = now.Add(reissueRetryInterval)
+			b.Logger().Warn("unable to reissue the client certificate, the current certificate is used until it expires",
+				"connection", name, "expiry", existing.certExpiry, "retry", existing.reissueAt, "error", err)
+			return existing, nil
+		}
+		return nil, err
+	}
+	if warning := conn.certExpiryWarning(now); len(warning) != 0 {
+		b.Logger().Warn(warning, "connection", name)
+	}
+
+	// The state of the circuit breaker is kept
+	conn.breaker = existing.breaker
+	existing.close()
+	b.connections[name] = conn
+	return conn, nil
+}
+
+// ResetConnection closes the client of the named connection and replaces the
+// connection. If newConn is nil, a new connection is created next time
+// Connection() is called.
+func (b *backend) ResetConnection(name string, newConn *ccpConnection) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if conn, ok := b.connections[name]; ok {
+		conn.close()
+		delete(b.connections, name)
+	}
+
+	if newConn != nil {
+		b.connections[name] = newConn
+	}
 }
@@ -0,0 +1,341 @@
+package ccpsecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	ccp "github.com/liviusnl/go-ccp"
+)
+
+var queryFormatRegExp = regexp.MustCompile("^((?i)(?:exact)|(?:regex))$")
+
+// ccpErrorCodeRegExp matches the CyberArk error code of a logical error,
+// e.g. APPAP004E
+var ccpErrorCodeRegExp = regexp.MustCompile(`^\s*([A-Z]{5}[0-9]{3}[EWI])\b`)
+
+// snakeCaseMapper is implemented by the responses of the CCP client
+type snakeCaseMapper interface {
+	MapSnakeCase() (map[string]interface{}, error)
+}
+
+// credentialRequest describes a password request against a named connection
+type credentialRequest struct {
+	// The name of the connection used for the request
+	Connection string `json:"connection"`
+	// Query selects a query request, instead of an object request
+	Query bool `json:"query"`
+	// The query format used by a query request
+	QueryFormat ccp.QueryFormat `json:"query_format"`
+	// The password request send to the CCP Web Service
+	Request ccp.PasswordRequest `json:"request"`
+	// The response fields returned to the client. If empty, all fields are
+	// returned. The fields are not part of the request send to CCP.
+	Fields []string `json:"-"`
+	// The template rendering the response data, if a format is selected
+	Template *template.Template `json:"-"`
+	// The number of seconds to wait for a password change in progress,
+	// overriding the connection. If nil, the connection is used.
+	PasswordChangeWait *int `json:"-"`
+}
+
+// validateFields checks that the fields are declared by the response schema.
+// Custom account properties are selected using the properties field.
+func validateFields(fields []string) error {
+	for _, field := range fields {
+		if _, ok := credentialResponseFields[field]; !ok {
+			return fmt.Errorf("unknown field %q: use %s", field, strings.Join(responseFieldNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// responseFieldNames returns the sorted names of the response fields
+func responseFieldNames() []string {
+	names := make([]string, 0, len(credentialResponseFields))
+	for name := range credentialResponseFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// responseData returns the response data returned to the client: the
+// requested fields and, if a template is selected, the rendered template.
+func (cr *credentialRequest) responseData(data map[string]interface{}) (map[string]interface{}, error) {
+	data = projectFields(data, cr.Fields)
+	if cr.Template == nil {
+		return data, nil
+	}
+
+	rendered, err := render(cr.Template, data)
+	if err != nil {
+		return nil, err
+	}
+	data = maps.Clone(data)
+	data["rendered"] = rendered
+	return data, nil
+}
+
+// projectFields returns the fields of the response data. If no fields are
+// set, all data is returned. CCP omits properties which are not set on the
+// account, so a field missing in the data is omitted as well.
+func projectFields(data map[string]interface{}, fields []string) map[string]interface{} {
+	if len(fields) == 0 {
+		return data
+	}
+
+	projected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, ok := data[field]; ok {
+			projected[field] = value
+		}
+	}
+	return projected
+}
+
+// parseQueryFormat converts the query_format string to a ccp.QueryFormat.
+// An empty string results in an exact query.
+func parseQueryFormat(s string) (ccp.QueryFormat, error) {
+	if len(s) == 0 {
+		return ccp.QueryFormatExact, nil
+	}
+
+	format := queryFormatRegExp.FindStringSubmatch(s)
+	if len(format) != 2 {
+		return ccp.QueryFormatExact, errors.New("invalid query_format: use exact or regex")
+	}
+	switch strings.ToLower(format[1]) {
+	case "regex":
+		return ccp.QueryFormatRegEx, nil
+	default:
+		return ccp.QueryFormatExact, nil
+	}
+}
+
+// credentialResponse is the outcome of a credential request
+type credentialResponse struct {
+	// The response as a snake case map
+	Data map[string]interface{}
+	// The CCP logical error, if the request failed
+	LogicalError string
+	// Warnings to be returned to the client
+	Warnings []string
+	// The TTL and max TTL of the lease
+	TTL    time.Duration
+	MaxTTL time.Duration
+}
+
+// ccpErrorCode returns the CyberArk error code of the logical error, or an
+// empty string if the logical error has no error code.
+func ccpErrorCode(logicalError string) string {
+	code := ccpErrorCodeRegExp.FindStringSubmatch(logicalError)
+	if len(code) != 2 {
+		return ""
+	}
+	return code[1]
+}
+
+// retrieve executes the credential request against the CCP Web Service of the
+// connection selected by the request.
+func (b *backend) retrieve(ctx context.Context, s logical.Storage, cr *credentialRequest) (*credentialResponse, error) {
+	conn, err := b.Connection(ctx, s, cr.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	cresp, err := b.cachedRequest(ctx, s, conn, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	cresp.TTL = time.Duration(conn.config.TTL) * time.Second
+	cresp.MaxTTL = time.Duration(conn.config.MaxTTL) * time.Second
+	if warning := conn.certExpiryWarning(time.Now()); len(warning) != 0 {
+		cresp.Warnings = append(cresp.Warnings, warning)
+	}
+	return cresp, nil
+}
+
+// cachedRequest executes the credential request, or returns the cached
+// response if the connection has caching enabled.
+func (b *backend) cachedRequest(ctx context.Context, s logical.Storage, conn *ccpConnection, cr *credentialRequest) (*credentialResponse, error) {
+	config := conn.config
+	if config.CacheTTL == 0 {
+		return b.request(ctx, conn, cr)
+	}
+
+	var cs logical.Storage
+	if config.CacheStorage {
+		cs = s
+	}
+	key, err := cacheKey(cr)
+	if err != nil {
+		return nil, err
+	}
+	cached, err := b.cache.get(ctx, cs, cr.Connection, key)
+	if err != nil {
+		b.Logger().Warn("unable to read the cached response", "connection", cr.Connection, "error", err)
+	}
+
+	now := time.Now()
+	if cached != nil && now.Before(cached.Expires) {
+		return &credentialResponse{
+			Data:         cached.Data,
+			LogicalError: cached.LogicalError,
+		}, nil
+	}
+
+	resp, err := b.request(ctx, conn, cr)
+	if err != nil {
+		if cached == nil || len(cached.LogicalError) != 0 {
+			return nil, err
+		}
+		b.Logger().Warn("serving a stale response", "connection", cr.Connection, "error", err)
+		return &credentialResponse{
+			Data: cached.Data,
+			Warnings: []string{
+				fmt.Sprintf("the CCP Web Service is unavailable, serving the response retrieved at %s", cached.Retrieved.Format(time.RFC3339)),
+			},
+		}, nil
+	}
+
+	if ttl := config.cacheTTL(resp); ttl > 0 {
+		e := &cacheEntry{
+			Data:         resp.Data,
+			LogicalError: resp.LogicalError,
+			Retrieved:    now,
+			Expires:      now.Add(ttl),
+		}
+		// Negative responses are not served stale
+		e.Evicts = e.Expires
+		if len(resp.LogicalError) == 0 {
+			e.Evicts = e.Expires.Add(time.Duration(config.CacheStaleIfErrorTTL) * time.Second)
+		}
+		if err := b.cache.put(ctx, cs, cr.Connection, key, e); err != nil {
+			b.Logger().Warn("unable to store the cached response", "connection", cr.Connection, "error", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// cacheTTL returns how long the response is cached. A response retrieved
+// during a password change is not cached, so the next request retrieves or
+// waits for the new password.
+func (c *clientConfig) cacheTTL(cresp *credentialResponse) time.Duration {
+	if passwordChangeInProcess(cresp) {
+		return 0
+	}
+	if len(cresp.LogicalError) != 0 {
+		return time.Duration(c.NegativeCacheTTL) * time.Second
+	}
+	return time.Duration(c.CacheTTL) * time.Second
+}
+
+// request executes the credential request against the CCP Web Service of the
+// connection. While a password change is in progress, CCP is polled until the
+// change completed or the password change wait of the request is exceeded.
+func (b *backend) request(ctx context.Context, conn *ccpConnection, cr *credentialRequest) (*credentialResponse, error) {
+	config := conn.config
+	return waitForPasswordChange(ctx, config.passwordChangeWait(cr), config.passwordChangePollInterval(), func() (*credentialResponse, error) {
+		return b.requestWithRetries(ctx, conn, cr)
+	})
+}
+
+// requestWithRetries executes the credential request against the CCP Web
+// Service hosts of the connection, retrying failed attempts according to the
+// retry policy of the connection.
+func (b *backend) requestWithRetries(ctx context.Context, conn *ccpConnection, cr *credentialRequest) (*credentialResponse, error) {
+	cresp, retries, err := conn.config.retryPolicy().do(ctx, func() (*credentialResponse, error) {
+		return b.attempt(ctx, conn, cr)
+	})
+	if retries == 0 {
+		return cresp, err
+	}
+
+	b.Logger().Debug("CCP request retried", "connection", cr.Connection, "retries", retries)
+	if err != nil {
+		return nil, fmt.Errorf("request failed after %d retries: %w", retries, err)
+	}
+	cresp.Warnings = append(cresp.Warnings, fmt.Sprintf("the request to the CCP Web Service was retried %d times", retries))
+	return cresp, nil
+}
+
+// attempt executes the credential request, if the circuit breaker of the
+// connection allows it. Transport failures are counted by the breaker.
+func (b *backend) attempt(ctx context.Context, conn *ccpConnection, cr *credentialRequest) (*credentialResponse, error) {
+	if err := conn.breaker.allow(time.Now()); err != nil {
+		return nil, err
+	}
+
+	cresp, err := b.requestHosts(ctx, conn, cr)
+	switch {
+	case err == nil:
+		conn.breaker.succeeded()
+	case ctx.Err() != nil || !transportError(err):
+		conn.breaker.release()
+	default:
+		conn.breaker.failed(time.Now())
+	}
+	return cresp, err
+}
+
+// requestHosts executes the credential request against the CCP Web Service
+// hosts of the connection. A host failing with a transport error is marked as
+// unhealthy and the next host is tried. An invalid response is returned
+// without trying the next host.
+func (b *backend) requestHosts(ctx context.Context, conn *ccpConnection, cr *credentialRequest) (*credentialResponse, error) {
+	config := conn.config
+	backoff := time.Duration(config.HostBackoff) * time.Second
+	maxBackoff := time.Duration(config.HostMaxBackoff) * time.Second
+
+	var errs error
+	for _, h := range conn.candidates(time.Now()) {
+		cresp, err := requestHost(ctx, h.client, cr)
+		if err == nil {
+			h.succeeded()
+			return cresp, nil
+		}
+		if ctx.Err() != nil || errors.Is(err, errInvalidResponse) {
+			return nil, err
+		}
+
+		b.Logger().Warn("CCP Web Service host failed", "connection", cr.Connection, "host", h.host, "error", err)
+		h.failed(time.Now(), backoff, maxBackoff)
+		errs = errors.Join(errs, fmt.Errorf("%s: %w", h.host, err))
+	}
+	return nil, fmt.Errorf("%w: %w", errCCPUnavailable, errs)
+}
+
+// requestHost executes the credential request using the client of a host.
+func requestHost(ctx context.Context, client *ccp.Client, cr *credentialRequest) (*credentialResponse, error) {
+	r := cr.Request
+	var response snakeCaseMapper
+	var logicalError string
+	var err error
+	if cr.Query {
+		response, logicalError, err = client.Query(ctx, &r, cr.QueryFormat)
+	} else {
+		response, logicalError, err = client.Request(ctx, &r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(logicalError) != 0 {
+		return &credentialResponse{LogicalError: logicalError}, nil
+	}
+
+	mr, err := response.MapSnakeCase()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidResponse, err)
+	}
+	return &credentialResponse{Data: normalizeResponse(mr)}, nil
+}
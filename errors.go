@@ -0,0 +1,73 @@
+package ccpsecrets
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// errCCPUnavailable is returned when no CCP Web Service host of a connection
+// could be reached or returned a valid response
+var errCCPUnavailable = errors.New("the CCP Web Service is unavailable")
+
+// errInvalidResponse is returned when the response of the CCP Web Service can
+// not be decoded
+var errInvalidResponse = errors.New("invalid response of the CCP Web Service")
+
+// transportError returns true if the error is a failure to reach the CCP Web
+// Service, as opposed to a failure processing its response
+func transportError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, errCCPUnavailable) || errors.As(err, &netErr)
+}
+
+// ccpErrorStatuses maps CyberArk error codes to the HTTP status of the
+// response. Logical errors with another code are returned as bad requests.
+var ccpErrorStatuses = map[string]int{
+	// Password object matching the request was not found
+	"APPAP004E": http.StatusNotFound,
+	// The application is not authorized to retrieve the password
+	"APPAP227E": http.StatusForbidden,
+	"APPAP229E": http.StatusForbidden,
+	// The password is being changed
+	passwordChangeErrorCode: http.StatusConflict,
+	// The Credential Provider could not connect to the Vault
+	"APPAP007E": http.StatusBadGateway,
+}
+
+// ccpErrorStatus returns the HTTP status of the logical error
+func ccpErrorStatus(logicalError string) int {
+	if status, ok := ccpErrorStatuses[ccpErrorCode(logicalError)]; ok {
+		return status
+	}
+	return http.StatusBadRequest
+}
+
+// logicalErrorResponse returns the response for a CCP logical error. The
+// response has the HTTP status mapped from the CyberArk error code and
+// contains the code in the ccp_error_code field.
+func logicalErrorResponse(req *logical.Request, logicalError string, warnings []string) (*logical.Response, error) {
+	resp := logical.ErrorResponse(logicalError)
+	resp.Data["ccp_error_code"] = ccpErrorCode(logicalError)
+	resp.Warnings = warnings
+	return logical.RespondWithStatusCode(resp, req, ccpErrorStatus(logicalError))
+}
+
+// retrieveErrorResponse converts an error of a credential request to the
+// response: an unknown or pending connection is a bad request, an open circuit breaker is
+// unavailable and an unreachable CCP Web Service or an invalid response is a
+// bad gateway. Other errors are internal errors.
+func retrieveErrorResponse(err error) (*logical.Response, error) {
+	switch {
+	case errors.Is(err, errUnknownConnection), errors.Is(err, errConnectionPending):
+		return logical.ErrorResponse(err.Error()), nil
+	case errors.Is(err, errBreakerOpen):
+		return nil, logical.CodedError(http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, errCCPUnavailable), errors.Is(err, errInvalidResponse):
+		return nil, logical.CodedError(http.StatusBadGateway, err.Error())
+	default:
+		return nil, err
+	}
+}
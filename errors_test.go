@@ -0,0 +1,58 @@
+package ccpsecrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestLogicalErrorResponse(t *testing.T) {
+	for logicalError, want := range map[string]int{
+		"APPAP004E Password object matching query [Safe=MySafe;Object=MyObject] was not found": http.StatusNotFound,
+		"APPAP229E Too many password objects matching query":                                   http.StatusForbidden,
+		"APPAP282E Password object is being reset by the CPM":                                  http.StatusConflict,
+		"APPAP007E Connection to the Vault has failed":                                         http.StatusBadGateway,
+		"APPAP133E Invalid query format":                                                       http.StatusBadRequest,
+	} {
+		resp, err := logicalErrorResponse(&logical.Request{}, logicalError, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Data[logical.HTTPStatusCode]; got != want {
+			t.Errorf("%s: got status %v, want %d", logicalError, got, want)
+		}
+
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(resp.Data[logical.HTTPRawBody].(string)), &body); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := body.Data["ccp_error_code"], ccpErrorCode(logicalError); got != want {
+			t.Errorf("%s: got ccp_error_code %v, want %s", logicalError, got, want)
+		}
+	}
+}
+
+func TestRetrieveErrorResponse(t *testing.T) {
+	for err, want := range map[error]int{
+		fmt.Errorf("%w: ccp1: connection reset by peer", errCCPUnavailable):  http.StatusBadGateway,
+		fmt.Errorf("%w: the CCP Web Service failed 5 times", errBreakerOpen): http.StatusServiceUnavailable,
+		fmt.Errorf("%w: unexpected end of JSON input", errInvalidResponse):   http.StatusBadGateway,
+	} {
+		_, got := retrieveErrorResponse(err)
+		var coded logical.HTTPCodedError
+		if !errors.As(got, &coded) || coded.Code() != want {
+			t.Errorf("%v: got %v, want status %d", err, got, want)
+		}
+	}
+
+	resp, err := retrieveErrorResponse(fmt.Errorf("%w: default", errUnknownConnection))
+	if err != nil || !resp.IsError() {
+		t.Fatalf("got %v %v: want an error response", resp, err)
+	}
+}
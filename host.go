@@ -0,0 +1,94 @@
+package ccpsecrets
+
+import (
+	"sync"
+	"time"
+
+	ccp "github.com/liviusnl/go-ccp"
+)
+
+const (
+	// hostSelectionFailover uses the hosts in the configured order
+	hostSelectionFailover = "failover"
+	// hostSelectionRoundRobin distributes the requests over the hosts
+	hostSelectionRoundRobin = "round_robin"
+)
+
+// ccpHost is a CCP Web Service host of a connection, with its health
+type ccpHost struct {
+	host   string
+	client *ccp.Client
+
+	lock       sync.Mutex
+	failures   int
+	retryAfter time.Time
+}
+
+// healthy returns true if the host is not backing off after a failure
+func (h *ccpHost) healthy(now time.Time) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return !now.Before(h.retryAfter)
+}
+
+// succeeded marks the host as healthy
+func (h *ccpHost) succeeded() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.failures = 0
+	h.retryAfter = time.Time{}
+}
+
+// failed marks the host as unhealthy. The back-off doubles with every
+// consecutive failure, up to the maximum back-off.
+func (h *ccpHost) failed(now time.Time, backoff, maxBackoff time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.failures++
+	for i := 1; i < h.failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	h.retryAfter = now.Add(backoff)
+}
+
+// candidates returns the hosts in the order they should be tried. Healthy
+// hosts are tried first, hosts which are backing off are tried last.
+func (c *ccpConnection) candidates(now time.Time) []*ccpHost {
+	start := 0
+	if c.config.HostSelection == hostSelectionRoundRobin {
+		c.lock.Lock()
+		start = c.next % len(c.hosts)
+		c.next++
+		c.lock.Unlock()
+	}
+
+	healthy := make([]*ccpHost, 0, len(c.hosts))
+	var unhealthy []*ccpHost
+	for i := range c.hosts {
+		h := c.hosts[(start+i)%len(c.hosts)]
+		if h.healthy(now) {
+			healthy = append(healthy, h)
+		} else {
+			unhealthy = append(unhealthy, h)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// healthyHosts returns the hosts which are currently considered healthy
+func (c *ccpConnection) healthyHosts() []string {
+	now := time.Now()
+	hosts := []string{}
+	for _, h := range c.hosts {
+		if h.healthy(now) {
+			hosts = append(hosts, h.host)
+		}
+	}
+	return hosts
+}
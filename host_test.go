@@ -0,0 +1,49 @@
+package ccpsecrets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnectionCandidates(t *testing.T) {
+	conn := &ccpConnection{
+		config: &clientConfig{HostSelection: hostSelectionFailover},
+		hosts: []*ccpHost{
+			{host: "ccp1"},
+			{host: "ccp2"},
+			{host: "ccp3"},
+		},
+	}
+
+	now := time.Now()
+	order := func() (hosts []string) {
+		for _, h := range conn.candidates(now) {
+			hosts = append(hosts, h.host)
+		}
+		return hosts
+	}
+
+	if got := order(); got[0] != "ccp1" || got[1] != "ccp2" || got[2] != "ccp3" {
+		t.Fatalf("got %v: want configured order", got)
+	}
+
+	// An unhealthy host is tried last, until its back-off has passed
+	conn.hosts[0].failed(now, time.Second, time.Minute)
+	if got := order(); got[0] != "ccp2" || got[2] != "ccp1" {
+		t.Fatalf("got %v: want ccp1 last", got)
+	}
+	if got := conn.healthyHosts(); len(got) != 2 {
+		t.Fatalf("got %v: want 2 healthy hosts", got)
+	}
+	conn.hosts[0].failed(now, time.Second, time.Minute)
+	if conn.hosts[0].healthy(now.Add(time.Second)) || !conn.hosts[0].healthy(now.Add(2*time.Second)) {
+		t.Fatal("expected the back-off to double after the second failure")
+	}
+	conn.hosts[0].succeeded()
+
+	conn.config.HostSelection = hostSelectionRoundRobin
+	first, second := order()[0], order()[0]
+	if first == second {
+		t.Fatalf("got %v twice: want round robin", first)
+	}
+}
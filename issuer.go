@@ -0,0 +1,198 @@
+package ccpsecrets
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// certSourceStatic uses the client certificate stored in the config
+	certSourceStatic = "static"
+	// certSourceIssuer uses short-lived client certificates issued by the
+	// issuer of the connection
+	certSourceIssuer = "issuer"
+)
+
+// reissueRetryInterval is the time after which a failed reissue of a client
+// certificate is retried
+const reissueRetryInterval = 30 * time.Second
+
+// issuerEntry configures the issuer of the client certificates of a
+// connection
+type issuerEntry struct {
+	// The PEM encoded CA certificate and key signing the client certificates
+	CACert []byte `json:"ca_cert"`
+	CAKey  []byte `json:"ca_key"`
+	// The common name of the issued client certificates
+	CommonName string `json:"common_name"`
+	// The number of seconds an issued client certificate is valid
+	TTL int `json:"ttl"`
+	// The number of seconds before its expiry a client certificate is
+	// reissued
+	ReissueBefore int `json:"reissue_before"`
+}
+
+// issuerKey returns the storage key of the issuer of the named connection
+func issuerKey(name string) string {
+	return issuerPath + "/" + name
+}
+
+// getIssuer returns the issuer of the named connection, or nil if the
+// connection has no issuer.
+func getIssuer(ctx context.Context, s logical.Storage, name string) (*issuerEntry, error) {
+	entry, err := s.Get(ctx, issuerKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	issuer := &issuerEntry{}
+	if err := entry.DecodeJSON(issuer); err != nil {
+		return nil, err
+	}
+	return issuer, nil
+}
+
+// certSigner signs the client certificates issued to a connection
+type certSigner interface {
+	// Sign returns the DER encoded certificate for the template and public
+	// key.
+	Sign(template *x509.Certificate, pub crypto.PublicKey) ([]byte, error)
+}
+
+// caSigner signs certificates using a CA certificate and key
+type caSigner struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func (s *caSigner) Sign(template *x509.Certificate, pub crypto.PublicKey) ([]byte, error) {
+	return x509.CreateCertificate(rand.Reader, template, s.cert, pub, s.key)
+}
+
+// signer returns the signer of the issuer
+func (i *issuerEntry) signer() (certSigner, error) {
+	pair, err := tls.X509KeyPair(i.CACert, i.CAKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, errors.New("ca_cert is not a CA certificate")
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("ca_key is not a signing key")
+	}
+
+	return &caSigner{cert: cert, key: key}, nil
+}
+
+// issue generates a key pair and returns the PEM encoded client certificate
+// and key signed by the signer.
+func (i *issuerEntry) issue(signer certSigner, now time.Time) ([]byte, []byte, error) {
+	key, err := generateKey("ec", 256)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := signer.Sign(&x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: i.CommonName},
+		NotBefore:    now.Add(-30 * time.Second),
+		NotAfter:     now.Add(time.Duration(i.TTL) * time.Second),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		nil
+}
+
+// buildConnection creates the connection for the config. If the connection
+// uses an issuer, a client certificate is issued first. The issued
+// certificate is only kept in memory.
+func buildConnection(ctx context.Context, s logical.Storage, name string, config *clientConfig) (*ccpConnection, error) {
+	if config.pendingSignedCert() {
+		return nil, fmt.Errorf("%w: generate a CSR using config/%s/generate-csr and set the signed certificate using config/%s/set-signed-cert", errConnectionPending, name, name)
+	}
+	if config.CertSource != certSourceIssuer {
+		return newConnection(config)
+	}
+
+	issuer, err := getIssuer(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, fmt.Errorf("configure the issuer with config/%s/issuer first", name)
+	}
+	signer, err := issuer.signer()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the issuer: %w", err)
+	}
+
+	now := time.Now()
+	issued := *config
+	issued.ClientCert, issued.ClientKey, err = issuer.issue(signer, now)
+	if err != nil {
+		return nil, fmt.Errorf("unable to issue a client certificate: %w", err)
+	}
+
+	conn, err := newConnection(&issued)
+	if err != nil {
+		return nil, err
+	}
+	conn.reissueAt = now.Add(time.Duration(issuer.TTL-issuer.ReissueBefore) * time.Second)
+	return conn, nil
+}
+
+// reissueCertificates is called periodically to reissue the client
+// certificates of connections, before the certificates expire.
+func (b *backend) reissueCertificates(ctx context.Context, req *logical.Request) error {
+	now := time.Now()
+	var names []string
+	b.lock.Lock()
+	for name, conn := range b.connections {
+		if conn.needsReissue(now) {
+			names = append(names, name)
+		}
+	}
+	b.lock.Unlock()
+
+	var errs error
+	for _, name := range names {
+		if _, err := b.Connection(ctx, req.Storage, name); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("unable to reissue the client certificate of %s: %w", name, err))
+		}
+	}
+	return errs
+}
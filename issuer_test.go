@@ -0,0 +1,104 @@
+package ccpsecrets
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestConfigIssuer(t *testing.T) {
+	b, s := testBackend(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRequest(t, b, s, logical.UpdateOperation, "config/default/issuer", map[string]interface{}{
+		"ca_cert":        string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		"ca_key":         string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		"common_name":    "vault",
+		"ttl":            600,
+		"reissue_before": 60,
+	})
+	testRequest(t, b, s, logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":              "ccp.example.com",
+		"application_id":    "MyApp",
+		"cert_source":       "issuer",
+		"verify_connection": false,
+	})
+
+	resp := testRequest(t, b, s, logical.ReadOperation, "config/default", nil)
+	expiry, err := time.Parse(time.RFC3339, resp.Data["client_cert_expiry"].(string))
+	if err != nil {
+		t.Fatalf("expected an issued client certificate: %v", err)
+	}
+	if d := time.Until(expiry); d <= 0 || d > 10*time.Minute {
+		t.Fatalf("got expiry %v: want within the issuer ttl", expiry)
+	}
+
+	// A connection past its reissue time is rebuilt with a new certificate
+	bk := b.(*backend)
+	conn, err := bk.Connection(context.Background(), s, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.reissueAt = time.Now().Add(-time.Second)
+	if err := bk.reissueCertificates(context.Background(), &logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	reissued, err := bk.Connection(context.Background(), s, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reissued == conn {
+		t.Fatal("expected the connection to be rebuilt")
+	}
+
+	// If the reissue fails, the connection is used until its certificate
+	// expires
+	if err := s.Delete(context.Background(), issuerKey("default")); err != nil {
+		t.Fatal(err)
+	}
+	reissued.reissueAt = time.Now().Add(-time.Second)
+	current, err := bk.Connection(context.Background(), s, "default")
+	if err != nil || current != reissued {
+		t.Fatalf("got %v: want the current connection", err)
+	}
+
+	// The failed reissue is retried after the retry interval
+	if d := time.Until(current.reissueAt); d <= 0 || d > reissueRetryInterval {
+		t.Fatalf("got reissue in %v: want a retry within %v", d, reissueRetryInterval)
+	}
+	reissued.reissueAt = time.Now().Add(-time.Second)
+	reissued.certExpiry = time.Now().Add(-time.Second)
+	if _, err := bk.Connection(context.Background(), s, "default"); err == nil {
+		t.Fatal("expected an error after the certificate expired")
+	}
+}
@@ -0,0 +1,50 @@
+package ccpsecrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestOpenAPI compares the OpenAPI document of the backend to the golden file.
+// Run the test with -update after changing a path.
+func TestOpenAPI(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System.(*logical.StaticSystemView).PluginEnvironment = &logical.PluginEnvironment{
+		VaultVersion: "1.14.0",
+	}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := testRequest(t, b, config.StorageView, logical.HelpOperation, "", nil)
+	got, err := json.MarshalIndent(resp.Data["openapi"], "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", "openapi.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("OpenAPI document differs from %s, run the test with -update to regenerate it", golden)
+	}
+}
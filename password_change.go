@@ -0,0 +1,108 @@
+package ccpsecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+)
+
+// passwordChangeErrorCode is returned by CCP for a request failing during a
+// password change, if fail_request_on_password_change is set
+const passwordChangeErrorCode = "APPAP282E"
+
+const (
+	// defaultPasswordChangePollInterval is the number of seconds between
+	// polls, if the connection has no poll interval
+	defaultPasswordChangePollInterval = 5
+	// minPasswordChangePollInterval limits how often CCP is polled
+	minPasswordChangePollInterval = time.Second
+	// defaultMaxPasswordChangeWait is the number of seconds a request can
+	// override the password_change_wait with, if not set on the connection
+	defaultMaxPasswordChangeWait = 300
+)
+
+// passwordChangeWaitField is the request field overriding the
+// password_change_wait of the connection
+var passwordChangeWaitField = &framework.FieldSchema{
+	Type:        framework.TypeInt,
+	Description: `The number of seconds to wait for a password change in progress to complete. Overrides the password_change_wait of the connection, zero disables waiting.`,
+	Query:       true,
+}
+
+// passwordChangeWaitOverride returns the password_change_wait of the request,
+// or nil if the request does not override the connection.
+func passwordChangeWaitOverride(data *framework.FieldData) (*int, error) {
+	raw, ok := data.GetOk("password_change_wait")
+	if !ok {
+		return nil, nil
+	}
+	wait := raw.(int)
+	if wait < 0 {
+		return nil, errors.New("password_change_wait must be positive")
+	}
+	return &wait, nil
+}
+
+// passwordChangeWait returns how long the request waits for a password change
+// in progress to complete. The override of the request is limited to the
+// max_password_change_wait of the connection, or its password_change_wait if
+// greater.
+func (c *clientConfig) passwordChangeWait(cr *credentialRequest) time.Duration {
+	if cr.PasswordChangeWait == nil {
+		return time.Duration(c.PasswordChangeWait) * time.Second
+	}
+	wait := min(*cr.PasswordChangeWait, max(c.MaxPasswordChangeWait, c.PasswordChangeWait))
+	return time.Duration(wait) * time.Second
+}
+
+// passwordChangePollInterval returns the interval between polls while waiting
+// for a password change, at least minPasswordChangePollInterval
+func (c *clientConfig) passwordChangePollInterval() time.Duration {
+	return max(time.Duration(c.PasswordChangePollInterval)*time.Second, minPasswordChangePollInterval)
+}
+
+// passwordChangeInProcess returns true if CCP reports a password change in
+// progress, either as logical error or in the response
+func passwordChangeInProcess(cresp *credentialResponse) bool {
+	if len(cresp.LogicalError) != 0 {
+		return ccpErrorCode(cresp.LogicalError) == passwordChangeErrorCode
+	}
+	inProcess, _ := cresp.Data["password_change_in_process"].(bool)
+	return inProcess
+}
+
+// waitForPasswordChange polls CCP while a password change is in progress, so
+// the new password is returned. Polling ends when the change completed, the
+// wait is exceeded or the context is done, then the last response is returned.
+func waitForPasswordChange(ctx context.Context, wait, interval time.Duration, request func() (*credentialResponse, error)) (*credentialResponse, error) {
+	start := time.Now()
+	deadline := start.Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	for polls := 0; ; polls++ {
+		cresp, err := request()
+		if err != nil || wait == 0 || !passwordChangeInProcess(cresp) {
+			if err == nil && polls != 0 {
+				cresp.Warnings = append(cresp.Warnings, fmt.Sprintf("waited %s for the password change to complete", time.Since(start).Round(time.Second)))
+			}
+			return cresp, err
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			cresp.Warnings = append(cresp.Warnings, fmt.Sprintf("the password change is still in progress after waiting %s", time.Since(start).Round(time.Second)))
+			return cresp, nil
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return cresp, nil
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package ccpsecrets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestWaitForPasswordChange(t *testing.T) {
+	ctx := context.Background()
+	inProcess := &credentialResponse{Data: map[string]interface{}{"content": "old", "password_change_in_process": true}}
+	changed := &credentialResponse{Data: map[string]interface{}{"content": "new", "password_change_in_process": false}}
+
+	// CCP is polled until the change completed
+	polls := 0
+	cresp, err := waitForPasswordChange(ctx, time.Minute, time.Millisecond, func() (*credentialResponse, error) {
+		polls++
+		if polls < 3 {
+			return inProcess, nil
+		}
+		return changed, nil
+	})
+	if err != nil || cresp.Data["content"] != "new" || polls != 3 || len(cresp.Warnings) != 1 {
+		t.Fatalf("got %v after %d polls, %v: want the new password after 3 polls", cresp.Data, polls, err)
+	}
+
+	// The password change error is polled as well
+	polls = 0
+	cresp, err = waitForPasswordChange(ctx, time.Minute, time.Millisecond, func() (*credentialResponse, error) {
+		polls++
+		if polls == 1 {
+			return &credentialResponse{LogicalError: "APPAP282E Password object is being reset by the CPM"}, nil
+		}
+		return &credentialResponse{Data: map[string]interface{}{"content": "new"}}, nil
+	})
+	if err != nil || cresp.Data["content"] != "new" || polls != 2 {
+		t.Fatalf("got %v after %d polls, %v: want the new password after 2 polls", cresp, polls, err)
+	}
+
+	// Without a wait, the response is returned immediately
+	polls = 0
+	cresp, _ = waitForPasswordChange(ctx, 0, time.Millisecond, func() (*credentialResponse, error) {
+		polls++
+		return inProcess, nil
+	})
+	if polls != 1 || cresp.Data["content"] != "old" {
+		t.Fatalf("got %d polls: want 1", polls)
+	}
+
+	// When the wait is exceeded, the last response is returned with a warning
+	polls = 0
+	cresp, _ = waitForPasswordChange(ctx, time.Second, time.Minute, func() (*credentialResponse, error) {
+		polls++
+		return &credentialResponse{Data: inProcess.Data}, nil
+	})
+	if polls != 1 || len(cresp.Warnings) != 1 {
+		t.Fatalf("got %d polls, warnings %v: want 1 poll and a warning", polls, cresp.Warnings)
+	}
+}
+
+func TestPasswordChangeWait(t *testing.T) {
+	config := &clientConfig{PasswordChangeWait: 60}
+	if got := config.passwordChangeWait(&credentialRequest{}); got != time.Minute {
+		t.Fatalf("got %v: want the wait of the connection", got)
+	}
+	override := 0
+	if got := config.passwordChangeWait(&credentialRequest{PasswordChangeWait: &override}); got != 0 {
+		t.Fatalf("got %v: want the wait of the request", got)
+	}
+
+	// The override is limited to the max_password_change_wait, or the wait
+	// of the connection if greater
+	override = 3600
+	if got := config.passwordChangeWait(&credentialRequest{PasswordChangeWait: &override}); got != time.Minute {
+		t.Fatalf("got %v: want the wait of the connection", got)
+	}
+	config.MaxPasswordChangeWait = 300
+	if got := config.passwordChangeWait(&credentialRequest{PasswordChangeWait: &override}); got != 5*time.Minute {
+		t.Fatalf("got %v: want max_password_change_wait", got)
+	}
+}
+
+func TestPasswordChangePollInterval(t *testing.T) {
+	// A config stored without a poll interval uses the default
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	entry, err := logical.StorageEntryJSON(connectionKey("default"), map[string]interface{}{"hosts": []string{"ccp.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+	config, err := getConfig(ctx, s, "default")
+	if err != nil || config.PasswordChangePollInterval != defaultPasswordChangePollInterval {
+		t.Fatalf("got %+v, %v: want the default poll interval", config, err)
+	}
+
+	if got := (&clientConfig{}).passwordChangePollInterval(); got != minPasswordChangePollInterval {
+		t.Fatalf("got %v: want the minimum interval", got)
+	}
+	if got := (&clientConfig{PasswordChangePollInterval: 10}).passwordChangePollInterval(); got != 10*time.Second {
+		t.Fatalf("got %v: want the interval of the connection", got)
+	}
+}
@@ -0,0 +1,216 @@
+package ccpsecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// defaultBatchParallelism is the number of requests of a batch executed
+	// concurrently, if no parallelism is set
+	defaultBatchParallelism = 4
+	// maxBatchParallelism limits the parallelism of a batch
+	maxBatchParallelism = 16
+	// maxBatchSize limits the number of requests of a batch
+	maxBatchSize = 100
+)
+
+// batchItem is a single request of a batch, bound by the role of the batch
+// like a creds request
+type batchItem struct {
+	Safe   string   `mapstructure:"safe"`
+	Folder string   `mapstructure:"folder"`
+	Object string   `mapstructure:"object"`
+	Fields []string `mapstructure:"fields"`
+	Format string   `mapstructure:"format"`
+	// The number of seconds to wait for a password change in progress,
+	// overriding the connection
+	PasswordChangeWait *int `mapstructure:"password_change_wait"`
+}
+
+// decodeBatchItem decodes a request of a batch. Unknown keys are rejected, so
+// a request cannot name another role.
+func decodeBatchItem(raw interface{}) (*batchItem, error) {
+	item := &batchItem{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+		Result:           item,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// credentialRequest converts the item to a credential request, after
+// verifying that the safe, folder, object and fields are allowed by the role.
+func (i *batchItem) credentialRequest(role *roleEntry) (*credentialRequest, error) {
+	cr, err := role.credentialRequest(i.Safe, i.Folder, i.Object, i.Fields)
+	if err != nil {
+		return nil, err
+	}
+	if i.PasswordChangeWait != nil && *i.PasswordChangeWait < 0 {
+		return nil, errors.New("password_change_wait must be positive")
+	}
+	cr.PasswordChangeWait = i.PasswordChangeWait
+	return cr, nil
+}
+
+// batchResponseFields are the fields of a batch
+var batchResponseFields = map[string]*framework.FieldSchema{
+	"results": {
+		Type:        framework.TypeSlice,
+		Description: `The result per request, in the order of the requests. A result contains the data or the error of the request.`,
+	},
+}
+
+// pathBatch returns the path configuration to retrieve multiple objects bound
+// by a role in a single request
+func pathBatch(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: batchPath + "/" + framework.GenericNameRegex("name"),
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationVerb:   "batch-read",
+			OperationSuffix: "credentials",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the role.`,
+				Required:    true,
+			},
+			"requests": {
+				Type:        framework.TypeSlice,
+				Description: `The requests to execute. A request optionally contains the safe, folder and object, the response fields, a format and the password_change_wait, as a creds request.`,
+				Required:    true,
+			},
+			"parallelism": {
+				Type:        framework.TypeInt,
+				Description: fmt.Sprintf(`The number of requests executed concurrently. At most %d.`, maxBatchParallelism),
+				Default:     defaultBatchParallelism,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:  b.pathBatchWrite,
+				Responses: okResponses(batchResponseFields),
+			},
+		},
+
+		HelpSynopsis:    batchHelpSyn,
+		HelpDescription: batchHelpDesc,
+	}
+}
+
+// pathBatchWrite executes the requests of the batch concurrently. A failing
+// request does not fail the batch, its error is returned in its result.
+func (b *backend) pathBatchWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	items := data.Get("requests").([]interface{})
+	if len(items) == 0 {
+		return logical.ErrorResponse("no requests provided"), nil
+	}
+	if len(items) > maxBatchSize {
+		return logical.ErrorResponse("at most %d requests can be batched", maxBatchSize), nil
+	}
+	parallelism := data.Get("parallelism").(int)
+	if parallelism < 1 || parallelism > maxBatchParallelism {
+		return logical.ErrorResponse("parallelism must be between 1 and %d", maxBatchParallelism), nil
+	}
+
+	name := data.Get("name").(string)
+	role, err := getRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse("unknown role: %s", name), nil
+	}
+
+	results := make([]map[string]interface{}, len(items))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for n, raw := range items {
+		item, err := decodeBatchItem(raw)
+		if err != nil {
+			results[n] = map[string]interface{}{"error": fmt.Sprintf("invalid request: %v", err)}
+			continue
+		}
+		cr, err := item.credentialRequest(role)
+		if err == nil {
+			cr.Template, err = formatTemplate(ctx, req.Storage, item.Format)
+		}
+		if err != nil {
+			results[n] = map[string]interface{}{"error": err.Error()}
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(n int, cr *credentialRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[n] = b.batchResult(ctx, req.Storage, cr)
+		}(n, cr)
+	}
+	wg.Wait()
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"results": results,
+		},
+	}, nil
+}
+
+// batchResult executes a request of a batch and returns its result
+func (b *backend) batchResult(ctx context.Context, s logical.Storage, cr *credentialRequest) map[string]interface{} {
+	cresp, err := b.retrieve(ctx, s, cr)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	result := make(map[string]interface{})
+	if len(cresp.Warnings) != 0 {
+		result["warnings"] = cresp.Warnings
+	}
+	if len(cresp.LogicalError) != 0 {
+		result["error"] = cresp.LogicalError
+		result["ccp_error_code"] = ccpErrorCode(cresp.LogicalError)
+		return result
+	}
+	data, err := cr.responseData(cresp.Data)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	result["data"] = data
+	return result
+}
+
+const batchHelpSyn = `
+Retrieve multiple secrets bound by a role from the CyberArk Credentials Provider in one request.
+`
+const batchHelpDesc = `
+This endpoint executes creds requests for the role concurrently and returns a
+result per request, in the order of the requests. Every request must be allowed
+by the role, as a creds/<role> request. A failing request returns its error in
+its result, without failing the other requests.
+
+Vault policies apply per role: grant update on batch/<role> to the clients
+which may read creds/<role>.
+
+The retrieved secrets are not issued as leases, because a response can only
+carry a single lease. Use creds/<role> to retrieve a secret as a lease.
+`
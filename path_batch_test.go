@@ -0,0 +1,49 @@
+package ccpsecrets
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestBatchPartialFailure(t *testing.T) {
+	b, s := testBackend(t)
+
+	testRequest(t, b, s, logical.UpdateOperation, "roles/app", map[string]interface{}{
+		"safes":   "MySafe",
+		"objects": "MyObject",
+	})
+	resp := testRequest(t, b, s, logical.UpdateOperation, "batch/app", map[string]interface{}{
+		"requests": []interface{}{
+			map[string]interface{}{},
+			map[string]interface{}{"role": "other"},
+			map[string]interface{}{"safe": "OtherSafe"},
+			map[string]interface{}{"object": "OtherObject"},
+		},
+		"parallelism": 2,
+	})
+
+	results := resp.Data["results"].([]map[string]interface{})
+	if len(results) != 4 {
+		t.Fatalf("got %d results: want 4", len(results))
+	}
+	for n, want := range []string{"unknown connection", "invalid request", "not allowed by the role", "not allowed by the role"} {
+		if got, _ := results[n]["error"].(string); !strings.Contains(got, want) {
+			t.Errorf("result %d: got error %q, want %q", n, got, want)
+		}
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "batch/missing",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"requests": []interface{}{map[string]interface{}{}},
+		},
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("got %v, %v: want an error for an unknown role", resp, err)
+	}
+}
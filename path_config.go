@@ -2,70 +2,265 @@ package ccpsecrets
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
 )
 
-// pathConfig returns the path configuration for CRUD operations on the backend
-// configuration.
-func pathConfig(b *backend) *framework.Path {
+// pathConfig returns the path configurations for CRUD operations on the
+// backend connections.
+func pathConfig(b *backend) []*framework.Path {
+	return []*framework.Path{
+		pathConfigList(b),
+		pathConfigConnection(b),
+		pathConfigVerify(b),
+		pathConfigPEM(b),
+		pathConfigRotateCert(b),
+		pathConfigRollbackCert(b),
+		pathConfigGenerateCSR(b),
+		pathConfigSetSignedCert(b),
+		pathConfigIssuer(b),
+	}
+}
+
+// pathConfigList returns the path configuration for listing the connections.
+func pathConfigList(b *backend) *framework.Path {
 	return &framework.Path{
-		Pattern: configPath,
-		Fields: map[string]*framework.FieldSchema{
-			"host": {
-				Type:        framework.TypeString,
-				Description: `Host must be a host string, a host:port pair of the CCP Web Service.`,
-				Required:    true,
-			},
-			"application_id": {
-				Type:        framework.TypeString,
-				Description: `Application Identifier identifies the secrets engine aginst the CCP Web Service.`,
-				Required:    true,
-			},
-			"connection_timeout": {
-				Type:        framework.TypeInt,
-				Description: `The number of seconds that the Central Credential Provider will try to retrieve the password.`,
-				Default:     30,
-			},
-			"fail_request_on_password_change": {
-				Type:        framework.TypeBool,
-				Description: `Fail the request during a password change`,
-				Default:     false,
-			},
-			"client_cert": {
-				Type:        framework.TypeString,
-				Description: `The PEM enconded client certificate to autenticate Vault against the CCP Web Service`,
-			},
-			"client_key": {
-				Type:        framework.TypeString,
-				Description: `The PEM encoded client certificate key`,
+		Pattern: configPath + "/?$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationSuffix: "connections",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback:  b.pathConfigList,
+				Responses: listResponses,
 			},
-			"skip_tls_verify": {
-				Type:        framework.TypeBool,
-				Description: `Skip the verification of the CCP Web Service server certificate`,
-				Default:     false,
+		},
+
+		HelpSynopsis:    confListHelpSyn,
+		HelpDescription: confListHelpDesc,
+	}
+}
+
+// pathConfigConnection returns the path configuration for CRUD operations on
+// a named connection.
+func pathConfigConnection(b *backend) *framework.Path {
+	fields := map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: `The name of the connection.`,
+			Required:    true,
+		},
+		"host": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: `Host must be a host string, a host:port pair of the CCP Web Service. Multiple hosts can be provided for failover.`,
+			Required:    true,
+		},
+		"host_selection": {
+			Type:        framework.TypeString,
+			Description: `How a host is selected when multiple hosts are provided: failover or round_robin.`,
+			Default:     hostSelectionFailover,
+		},
+		"host_backoff": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds a host is skipped after a failure. Doubles with every consecutive failure.`,
+			Default:     10,
+		},
+		"host_max_backoff": {
+			Type:        framework.TypeInt,
+			Description: `The maximum number of seconds a failing host is skipped.`,
+			Default:     300,
+		},
+		"max_retries": {
+			Type:        framework.TypeInt,
+			Description: `The number of times a request failing with a transport error or a retryable CCP error code is retried. If zero, requests are not retried.`,
+			Default:     0,
+		},
+		"min_backoff": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds before the first retry. Doubles with every retry.`,
+			Default:     1,
+		},
+		"max_backoff": {
+			Type:        framework.TypeInt,
+			Description: `The maximum number of seconds between retries.`,
+			Default:     10,
+		},
+		"jitter": {
+			Type:        framework.TypeBool,
+			Description: `Randomize the back-off between retries.`,
+			Default:     true,
+		},
+		"retry_error_codes": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: `The CyberArk error codes of logical errors which are retried, e.g. APPAP007E.`,
+		},
+		"breaker_threshold": {
+			Type:        framework.TypeInt,
+			Description: `The number of consecutive transport failures opening the circuit breaker of the connection. While open, requests fail without contacting the CCP Web Service. If zero, the breaker never opens.`,
+			Default:     0,
+		},
+		"breaker_timeout": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds the circuit breaker stays open, before a probe request is passed to the CCP Web Service.`,
+			Default:     30,
+		},
+		"password_change_wait": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds a request waits for a password change in progress to complete, so the new password is returned. If zero, the response is returned immediately. Can be overridden per request.`,
+			Default:     0,
+		},
+		"password_change_poll_interval": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds between requests to CCP, while waiting for a password change to complete.`,
+			Default:     defaultPasswordChangePollInterval,
+		},
+		"max_password_change_wait": {
+			Type:        framework.TypeInt,
+			Description: `The maximum number of seconds a request can override password_change_wait with. A longer wait is limited to max_password_change_wait, or to password_change_wait if greater.`,
+			Default:     defaultMaxPasswordChangeWait,
+		},
+		"application_id": {
+			Type:        framework.TypeString,
+			Description: `Application Identifier identifies the secrets engine aginst the CCP Web Service.`,
+			Required:    true,
+		},
+		"connection_timeout": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds that the Central Credential Provider will try to retrieve the password.`,
+			Default:     30,
+		},
+		"fail_request_on_password_change": {
+			Type:        framework.TypeBool,
+			Description: `Fail the request during a password change`,
+			Default:     false,
+		},
+		"client_cert": {
+			Type:        framework.TypeString,
+			Description: `The PEM enconded client certificate to autenticate Vault against the CCP Web Service`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Sensitive: true,
 			},
-			"enable_tls_renegotiation": {
-				Type:        framework.TypeBool,
-				Description: `Enable TLS renegotiation`,
-				Default:     false,
+		},
+		"client_key": {
+			Type:        framework.TypeString,
+			Description: `The PEM encoded client certificate key`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Sensitive: true,
 			},
-			"root_ca": {
-				Type:        framework.TypeString,
-				Description: `Root CA is a PEM encoded certificate or bundle to verify the CCP Web Service Server Certificate`,
+		},
+		"skip_tls_verify": {
+			Type:        framework.TypeBool,
+			Description: `Skip the verification of the CCP Web Service server certificate`,
+			Default:     false,
+		},
+		"enable_tls_renegotiation": {
+			Type:        framework.TypeBool,
+			Description: `Enable TLS renegotiation`,
+			Default:     false,
+		},
+		"root_ca": {
+			Type:        framework.TypeString,
+			Description: `Root CA is a PEM encoded certificate or bundle to verify the CCP Web Service Server Certificate`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Sensitive: true,
 			},
 		},
+		"cache_ttl": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds responses are cached. If zero, responses are not cached. A cached response is returned without a request to CCP, so it is not recorded in the CCP audit log.`,
+			Default:     0,
+		},
+		"negative_cache_ttl": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds CCP logical errors are cached. If zero, logical errors are not cached. Requires cache_ttl.`,
+			Default:     0,
+		},
+		"cache_stale_if_error_ttl": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds an expired response is served, when the CCP Web Service is unreachable or fails. Expired responses are not refreshed in the background.`,
+			Default:     0,
+		},
+		"ttl": {
+			Type:        framework.TypeInt,
+			Description: `The default number of seconds of the lease of a retrieved secret. If zero, the system default is used.`,
+			Default:     0,
+		},
+		"max_ttl": {
+			Type:        framework.TypeInt,
+			Description: `The maximum number of seconds of the lease of a retrieved secret. If zero, the system default is used.`,
+			Default:     0,
+		},
+		"verify_safe": {
+			Type:        framework.TypeString,
+			Description: `The Safe of the object requested to verify the connection.`,
+		},
+		"verify_folder": {
+			Type:        framework.TypeString,
+			Description: `The folder of the object requested to verify the connection.`,
+		},
+		"verify_object": {
+			Type:        framework.TypeString,
+			Description: `The object requested to verify the connection. If not set, only the TLS connection is verified.`,
+		},
+		"cert_expiry_warning_window": {
+			Type:        framework.TypeInt,
+			Description: `The number of seconds before the expiry of the client certificate, warnings are added to responses.`,
+			Default:     30 * 24 * 60 * 60,
+		},
+		"force": {
+			Type:        framework.TypeBool,
+			Description: `Save the config, even if the client certificate has expired`,
+			Default:     false,
+		},
+		"auth_method": {
+			Type:        framework.TypeString,
+			Description: `The method used to authenticate against the CCP Web Service: mtls or none. If not set, mtls is used when a client certificate is configured, otherwise none. A connection using mtls without a client certificate is pending until set-signed-cert is used.`,
+		},
+		"cert_source": {
+			Type:        framework.TypeString,
+			Description: `The source of the client certificate: static uses client_cert and client_key, issuer issues short-lived client certificates using config/<name>/issuer.`,
+			Default:     certSourceStatic,
+		},
+		"verify_connection": {
+			Type:        framework.TypeBool,
+			Description: `Verify the connection to the CCP Web Service before the config is saved`,
+			Default:     true,
+		},
+		"cache_storage": {
+			Type:        framework.TypeBool,
+			Description: `Keep cached responses in seal wrapped storage, in addition to memory`,
+			Default:     false,
+		},
+	}
+	return &framework.Path{
+		Pattern: configPath + "/" + framework.GenericNameRegex("name"),
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationSuffix: "connection",
+		},
+		Fields: fields,
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathConfigWrite,
+				Callback:  b.pathConfigWrite,
+				Responses: noContentResponses,
 			},
 			logical.CreateOperation: &framework.PathOperation{
-				Callback: b.pathConfigWrite,
+				Callback:  b.pathConfigWrite,
+				Responses: noContentResponses,
 			},
 			logical.ReadOperation: &framework.PathOperation{
-				Callback: b.pathConfigRead,
+				Callback:  b.pathConfigRead,
+				Responses: okResponses(configResponseFields(fields)),
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback:  b.pathConfigDelete,
+				Responses: noContentResponses,
 			},
 		},
 		ExistenceCheck: b.pathConfigExists,
@@ -75,31 +270,195 @@ func pathConfig(b *backend) *framework.Path {
 	}
 }
 
+// configResponseFields returns the fields of a config read: the fields of the
+// config without the write only fields, and the details of the certificates.
+func configResponseFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	writeOnly := []string{"name", "client_cert", "client_key", "root_ca", "force", "verify_connection"}
+
+	response := map[string]*framework.FieldSchema{
+		"healthy_hosts": {
+			Type:        framework.TypeStringSlice,
+			Description: `The hosts which are not backing off after a failure.`,
+		},
+		"client_cert_info": {
+			Type:        framework.TypeSlice,
+			Description: `The details of the client certificate chain.`,
+		},
+		"client_cert_expiry": {
+			Type:        framework.TypeTime,
+			Description: `The expiry of the client certificate.`,
+		},
+		"staged_client_cert_info": {
+			Type:        framework.TypeSlice,
+			Description: `The details of the staged client certificate chain.`,
+		},
+		"previous_client_cert_info": {
+			Type:        framework.TypeSlice,
+			Description: `The details of the previous client certificate chain.`,
+		},
+		"root_ca_info": {
+			Type:        framework.TypeSlice,
+			Description: `The details of the root CA certificates.`,
+		},
+	}
+	for name, schema := range fields {
+		if !slices.Contains(writeOnly, name) {
+			response[name] = schema
+		}
+	}
+	return response
+}
+
+// configPEMResponseFields are the fields of a PEM read
+var configPEMResponseFields = map[string]*framework.FieldSchema{
+	"client_cert": {
+		Type:        framework.TypeString,
+		Description: `The PEM encoded client certificate.`,
+	},
+	"root_ca": {
+		Type:        framework.TypeString,
+		Description: `The PEM encoded root CA certificates.`,
+	},
+}
+
+// pathConfigPEM returns the path configuration for reading the PEM encoded
+// certificates of a named connection.
+func pathConfigPEM(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "/" + framework.GenericNameRegex("name") + "/pem$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationSuffix: "connection-pem",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection.`,
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:  b.pathConfigPEMRead,
+				Responses: okResponses(configPEMResponseFields),
+			},
+		},
+
+		HelpSynopsis:    confPEMHelpSyn,
+		HelpDescription: confPEMHelpDesc,
+	}
+}
+
+// pathConfigList handles list commands to the connections
+func (b *backend) pathConfigList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List(ctx, configPath+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(names), nil
+}
+
 // pathConfigRead handles read commands to the config
 func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	entry, err := req.Storage.Get(ctx, configPath)
+	name := data.Get("name").(string)
+	config, err := getConfig(ctx, req.Storage, name)
 	if err != nil {
 		return nil, err
 	}
-	if entry == nil {
-		return logical.ErrorResponse("configure the CCP client with config first"), nil
+	if config == nil {
+		return nil, nil
 	}
 
-	config := &clientConfig{}
-	if err := entry.DecodeJSON(config); err != nil {
-		return nil, err
+	clientCertInfo, err := certificatesInfo(config.ClientCert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client_cert: %w", err)
+	}
+	rootCAInfo, err := certificatesInfo(config.RootCA)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse root_ca: %w", err)
 	}
+	stagedClientCertInfo, err := certificatesInfo(config.StagedClientCert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the staged client_cert: %w", err)
+	}
+	previousClientCertInfo, err := certificatesInfo(config.PreviousClientCert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the previous client_cert: %w", err)
+	}
+	var clientCertExpiry string
+	if len(clientCertInfo) != 0 {
+		clientCertExpiry = clientCertInfo[0]["not_after"].(string)
+	}
+
+	healthyHosts := config.Hosts
+	b.lock.Lock()
+	if conn, ok := b.connections[name]; ok {
+		healthyHosts = conn.healthyHosts()
+		if config.CertSource == certSourceIssuer && !conn.certExpiry.IsZero() {
+			clientCertExpiry = conn.certExpiry.UTC().Format(time.RFC3339)
+		}
+	}
+	b.lock.Unlock()
 
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"host":                            config.Host,
+			"host":                            config.Hosts,
+			"host_selection":                  config.HostSelection,
+			"host_backoff":                    config.HostBackoff,
+			"host_max_backoff":                config.HostMaxBackoff,
+			"healthy_hosts":                   healthyHosts,
+			"max_retries":                     config.MaxRetries,
+			"min_backoff":                     config.MinBackoff,
+			"max_backoff":                     config.MaxBackoff,
+			"jitter":                          config.Jitter,
+			"retry_error_codes":               config.RetryErrorCodes,
+			"breaker_threshold":               config.BreakerThreshold,
+			"breaker_timeout":                 config.BreakerTimeout,
+			"password_change_wait":            config.PasswordChangeWait,
+			"password_change_poll_interval":   config.PasswordChangePollInterval,
+			"max_password_change_wait":        config.MaxPasswordChangeWait,
 			"application_id":                  config.ApplicationID,
 			"connection_timeout":              config.ConnectionTimeout,
 			"fail_request_on_password_change": config.FailRequestOnPasswordChange,
-			"client_cert":                     string(config.ClientCert),
+			"client_cert_info":                clientCertInfo,
 			"skip_tls_verify":                 config.SkipTLSVerify,
 			"enable_tls_renegotiation":        config.EnableTLSRenegotiation,
-			"root_ca":                         string(config.RootCA),
+			"client_cert_expiry":              clientCertExpiry,
+			"staged_client_cert_info":         stagedClientCertInfo,
+			"previous_client_cert_info":       previousClientCertInfo,
+			"root_ca_info":                    rootCAInfo,
+			"cert_expiry_warning_window":      config.CertExpiryWarningWindow,
+			"cert_source":                     config.CertSource,
+			"auth_method":                     config.AuthMethod,
+			"cache_ttl":                       config.CacheTTL,
+			"negative_cache_ttl":              config.NegativeCacheTTL,
+			"cache_stale_if_error_ttl":        config.CacheStaleIfErrorTTL,
+			"cache_storage":                   config.CacheStorage,
+			"ttl":                             config.TTL,
+			"max_ttl":                         config.MaxTTL,
+			"verify_safe":                     config.VerifySafe,
+			"verify_folder":                   config.VerifyFolder,
+			"verify_object":                   config.VerifyObject,
+		},
+	}
+	return resp, nil
+}
+
+// pathConfigPEMRead handles read commands to the PEM encoded certificates
+func (b *backend) pathConfigPEMRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"client_cert": string(config.ClientCert),
+			"root_ca":     string(config.RootCA),
 		},
 	}
 	return resp, nil
@@ -107,39 +466,208 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, data
 
 // pathConfigWrite handles create and update commands to the config
 func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	host := data.Get("host").(string)
-	if len(host) == 0 {
+	b.configLock.Lock()
+	defer b.configLock.Unlock()
+
+	name := data.Get("name").(string)
+	config, err := getConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	// An update is merged into the stored config, only the provided fields
+	// are changed. A field is cleared by providing an empty value.
+	update := req.Operation == logical.UpdateOperation && config != nil
+	if !update {
+		config = &clientConfig{}
+	}
+	provided := func(key string) (interface{}, bool) {
+		if update {
+			return data.GetOk(key)
+		}
+		return data.Get(key), true
+	}
+
+	if err := mapstructure.WeakDecode(data.Raw, config); err != nil {
+		return nil, err
+	}
+	if hosts, ok := provided("host"); ok {
+		config.Hosts = hosts.([]string)
+	}
+	if hostSelection, ok := provided("host_selection"); ok {
+		config.HostSelection = hostSelection.(string)
+	}
+	if hostBackoff, ok := provided("host_backoff"); ok {
+		config.HostBackoff = hostBackoff.(int)
+	}
+	if hostMaxBackoff, ok := provided("host_max_backoff"); ok {
+		config.HostMaxBackoff = hostMaxBackoff.(int)
+	}
+	if maxRetries, ok := provided("max_retries"); ok {
+		config.MaxRetries = maxRetries.(int)
+	}
+	if minBackoff, ok := provided("min_backoff"); ok {
+		config.MinBackoff = minBackoff.(int)
+	}
+	if maxBackoff, ok := provided("max_backoff"); ok {
+		config.MaxBackoff = maxBackoff.(int)
+	}
+	if jitter, ok := provided("jitter"); ok {
+		config.Jitter = jitter.(bool)
+	}
+	if retryErrorCodes, ok := provided("retry_error_codes"); ok {
+		config.RetryErrorCodes = retryErrorCodes.([]string)
+	}
+	if breakerThreshold, ok := provided("breaker_threshold"); ok {
+		config.BreakerThreshold = breakerThreshold.(int)
+	}
+	if breakerTimeout, ok := provided("breaker_timeout"); ok {
+		config.BreakerTimeout = breakerTimeout.(int)
+	}
+	if wait, ok := provided("password_change_wait"); ok {
+		config.PasswordChangeWait = wait.(int)
+	}
+	if interval, ok := provided("password_change_poll_interval"); ok {
+		config.PasswordChangePollInterval = interval.(int)
+	}
+	if maxWait, ok := provided("max_password_change_wait"); ok {
+		config.MaxPasswordChangeWait = maxWait.(int)
+	}
+	if window, ok := provided("cert_expiry_warning_window"); ok {
+		config.CertExpiryWarningWindow = window.(int)
+	}
+	if certSource, ok := provided("cert_source"); ok {
+		config.CertSource = certSource.(string)
+	}
+
+	if len(config.Hosts) == 0 {
 		return logical.ErrorResponse("no host provided"), nil
 	}
-	applicationID := data.Get("application_id").(string)
-	if len(applicationID) == 0 {
+	if config.HostSelection != hostSelectionFailover && config.HostSelection != hostSelectionRoundRobin {
+		return logical.ErrorResponse("invalid host_selection: use %s or %s", hostSelectionFailover, hostSelectionRoundRobin), nil
+	}
+	if len(config.ApplicationID) == 0 {
 		return logical.ErrorResponse("no application_id provided"), nil
 	}
-	connectionTimeout := data.Get("connection_timeout").(int)
-	if connectionTimeout < 0 {
+	if config.ConnectionTimeout < 0 {
 		return logical.ErrorResponse("connection_timeout must be positive"), nil
 	}
+	for _, field := range []string{"host_backoff", "host_max_backoff", "cache_ttl", "negative_cache_ttl", "cache_stale_if_error_ttl", "ttl", "max_ttl", "cert_expiry_warning_window", "breaker_threshold", "breaker_timeout", "password_change_wait", "password_change_poll_interval", "max_password_change_wait"} {
+		if data.Get(field).(int) < 0 {
+			return logical.ErrorResponse("%s must be positive", field), nil
+		}
+	}
+	if config.PasswordChangeWait != 0 && config.PasswordChangePollInterval == 0 {
+		return logical.ErrorResponse("password_change_poll_interval must be set to wait for password changes"), nil
+	}
+	if config.MaxTTL != 0 && config.TTL > config.MaxTTL {
+		return logical.ErrorResponse("ttl must not be greater than max_ttl"), nil
+	}
+	switch config.CertSource {
+	case certSourceStatic:
+	case certSourceIssuer:
+		if len(config.ClientCert) != 0 || len(config.ClientKey) != 0 {
+			return logical.ErrorResponse("client_cert and client_key can not be used with cert_source %s", certSourceIssuer), nil
+		}
+	default:
+		return logical.ErrorResponse("invalid cert_source: use %s or %s", certSourceStatic, certSourceIssuer), nil
+	}
+	strategy, err := config.authStrategy()
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	// A connection using mtls without a client certificate is pending, until
+	// the signed certificate of its CSR is set
+	pending := config.pendingSignedCert()
+	if err := strategy.validate(config); err != nil && !pending {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if err := config.validateRetry(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
 
-	var config clientConfig
-	if err := mapstructure.WeakDecode(data.Raw, &config); err != nil {
-		return nil, err
+	if pending {
+		if err := putConfig(ctx, req.Storage, name, config); err != nil {
+			return nil, err
+		}
+		b.ResetConnection(name, nil)
+		if err := b.cache.purgeStorage(ctx, req.Storage, name); err != nil {
+			return nil, err
+		}
+
+		resp := &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("connection %s is pending a client certificate: generate a CSR using config/%s/generate-csr and set the signed certificate using config/%s/set-signed-cert", name, name, name))
+		return resp, nil
 	}
 
-	client, err := createClient(&config)
+	expiry, err := config.clientCertExpiry()
 	if err != nil {
-		return logical.ErrorResponse("unable to create the CCP client: %v", err), nil
+		return logical.ErrorResponse("unable to parse client_cert: %v", err), nil
+	}
+	if !expiry.IsZero() && !time.Now().Before(expiry) && !data.Get("force").(bool) {
+		return logical.ErrorResponse("the client certificate expired at %s, use force to save it anyway", expiry.UTC().Format(time.RFC3339)), nil
 	}
 
-	entry, err := logical.StorageEntryJSON(configPath, config)
+	conn, err := buildConnection(ctx, req.Storage, name, config)
 	if err != nil {
+		return logical.ErrorResponse("unable to create the CCP client: %v", err), nil
+	}
+
+	var resp *logical.Response
+	if warning := conn.certExpiryWarning(time.Now()); len(warning) != 0 {
+		resp = &logical.Response{}
+		resp.AddWarning(warning)
+	}
+	if data.Get("verify_connection").(bool) {
+		hosts, ok := verifyConnection(ctx, conn)
+		if !ok {
+			conn.close()
+			resp = logical.ErrorResponse("unable to verify the connection to the CCP Web Service")
+			resp.Data["hosts"] = hosts
+			return resp, nil
+		}
+		for _, host := range hosts {
+			if !host["verified"].(bool) {
+				if resp == nil {
+					resp = &logical.Response{}
+				}
+				resp.AddWarning(fmt.Sprintf("unable to verify host %s: %v", host["host"], host["error"]))
+			}
+		}
+	}
+
+	if err := putConfig(ctx, req.Storage, name, config); err != nil {
 		return nil, err
 	}
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	b.ResetConnection(name, conn)
+	if err := b.cache.purgeStorage(ctx, req.Storage, name); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// pathConfigDelete handles delete commands to the config. The generated CSR,
+// the issuer and the cached responses of the connection are removed as well.
+func (b *backend) pathConfigDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configLock.Lock()
+	defer b.configLock.Unlock()
+
+	name := data.Get("name").(string)
+	for _, key := range []string{connectionKey(name), csrKey(name), issuerKey(name)} {
+		if err := req.Storage.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := deleteInventories(ctx, req.Storage, name); err != nil {
 		return nil, err
 	}
 
-	b.ResetClient(client)
+	b.ResetConnection(name, nil)
+	if err := b.cache.purgeStorage(ctx, req.Storage, name); err != nil {
+		return nil, err
+	}
 
 	return nil, nil
 }
@@ -153,11 +681,39 @@ func (b *backend) pathConfigExists(ctx context.Context, req *logical.Request, da
 	return out != nil, nil
 }
 
+const confListHelpSyn = `
+List the configured connections to CyberArk Credentials Provider Web Services.
+`
+const confListHelpDesc = `
+This endpoint lists the names of the configured connections. The name of a
+connection is used to select the connection on object and query requests.
+`
+
+const confPEMHelpSyn = `
+Read the PEM encoded certificates of a connection.
+`
+const confPEMHelpDesc = `
+This endpoint returns the PEM encoded client certificate and root CA of a
+connection. Reading the config only returns the fingerprint, subject, issuer
+and expiry of the certificates. This endpoint requires sudo capability.
+`
+
 const confHelpSyn = `
 Configure the CyberArk Credentials Provider API server and authentication information.
 `
 const confHelpDesc = `
-This endpoint allows you to configure the connection to the CyberArk Credentials
-Provider Web Service. Here you add or update a config. It takes immediate effect
-on all subsequent actions.
+This endpoint allows you to configure a named connection to a CyberArk
+Credentials Provider Web Service. Here you add, update or delete a config.
+It takes immediate effect on all subsequent actions using the connection.
+Requests which do not select a connection use the "default" connection.
+An update only changes the provided fields, an empty value clears a field.
+Deleting a config removes its certificates, generated CSR, issuer and cached
+responses. Requests using the deleted connection fail until it is configured
+again.
+
+A connection with auth_method mtls and without client_cert and client_key is
+pending: requests using it fail until the client certificate is set. To keep
+the client key inside Vault, write the pending connection, generate the key and
+CSR using config/<name>/generate-csr and set the certificate signed by your CA
+using config/<name>/set-signed-cert.
 `
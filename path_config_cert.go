@@ -0,0 +1,253 @@
+package ccpsecrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// rotateCertResponseFields are the fields of a client certificate rotation
+var rotateCertResponseFields = map[string]*framework.FieldSchema{
+	"client_cert_expiry": {
+		Type:        framework.TypeTime,
+		Description: `The expiry of the promoted client certificate.`,
+	},
+	"hosts": {
+		Type:        framework.TypeSlice,
+		Description: `The verification result per host.`,
+	},
+}
+
+// pathConfigRotateCert returns the path configuration to rotate the client
+// certificate of a connection
+func pathConfigRotateCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "/" + framework.GenericNameRegex("name") + "/rotate-cert$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationVerb:   "rotate",
+			OperationSuffix: "client-certificate",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection.`,
+				Required:    true,
+			},
+			"client_cert": {
+				Type:        framework.TypeString,
+				Description: `The PEM enconded client certificate to stage. If not set, the staged client certificate is promoted.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+			"client_key": {
+				Type:        framework.TypeString,
+				Description: `The PEM encoded client certificate key to stage.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:  b.pathConfigRotateCertWrite,
+				Responses: okResponses(rotateCertResponseFields),
+			},
+		},
+
+		HelpSynopsis:    confRotateCertHelpSyn,
+		HelpDescription: confRotateCertHelpDesc,
+	}
+}
+
+// pathConfigRollbackCert returns the path configuration to roll back the
+// client certificate of a connection
+func pathConfigRollbackCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "/" + framework.GenericNameRegex("name") + "/rollback-cert$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationVerb:   "roll-back",
+			OperationSuffix: "client-certificate",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection.`,
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:  b.pathConfigRollbackCertWrite,
+				Responses: noContentResponses,
+			},
+		},
+
+		HelpSynopsis:    confRollbackCertHelpSyn,
+		HelpDescription: confRollbackCertHelpDesc,
+	}
+}
+
+// pathConfigRotateCertWrite stages a new client certificate, verifies it
+// against the CCP Web Service and promotes it.
+func (b *backend) pathConfigRotateCertWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configLock.Lock()
+	defer b.configLock.Unlock()
+
+	name := data.Get("name").(string)
+	config, err := getConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("unknown connection: %s", name), nil
+	}
+	if config.CertSource == certSourceIssuer {
+		return logical.ErrorResponse("the client certificate of connection %s is issued by its issuer", name), nil
+	}
+	if config.AuthMethod == authMethodNone {
+		return logical.ErrorResponse("connection %s uses auth_method %s", name, authMethodNone), nil
+	}
+
+	clientCert := data.Get("client_cert").(string)
+	clientKey := data.Get("client_key").(string)
+	switch {
+	case len(clientCert) != 0 && len(clientKey) != 0:
+		config.StagedClientCert = []byte(clientCert)
+		config.StagedClientKey = []byte(clientKey)
+		if err := putConfig(ctx, req.Storage, name, config); err != nil {
+			return nil, err
+		}
+	case len(clientCert) != 0 || len(clientKey) != 0:
+		return logical.ErrorResponse("both client_cert and client_key must be provided"), nil
+	case len(config.StagedClientCert) == 0:
+		return logical.ErrorResponse("no client_cert provided and no client certificate staged"), nil
+	}
+
+	promoted := *config
+	promoted.ClientCert, promoted.ClientKey = config.StagedClientCert, config.StagedClientKey
+	promoted.PreviousClientCert, promoted.PreviousClientKey = config.ClientCert, config.ClientKey
+	promoted.StagedClientCert, promoted.StagedClientKey = nil, nil
+
+	expiry, err := promoted.clientCertExpiry()
+	if err != nil {
+		return logical.ErrorResponse("unable to parse the staged client_cert: %v", err), nil
+	}
+	if !time.Now().Before(expiry) {
+		return logical.ErrorResponse("the staged client certificate expired at %s", expiry.UTC().Format(time.RFC3339)), nil
+	}
+
+	conn, err := newConnection(&promoted)
+	if err != nil {
+		return logical.ErrorResponse("unable to create the CCP client using the staged certificate: %v", err), nil
+	}
+	hosts, ok := verifyConnection(ctx, conn)
+	if !ok {
+		conn.close()
+		resp := logical.ErrorResponse("unable to verify the staged certificate against the CCP Web Service, the certificate remains staged")
+		resp.Data["hosts"] = hosts
+		return logical.RespondWithStatusCode(resp, req, http.StatusBadRequest)
+	}
+
+	if err := putConfig(ctx, req.Storage, name, &promoted); err != nil {
+		conn.close()
+		return nil, err
+	}
+	b.ResetConnection(name, conn)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"client_cert_expiry": expiry.UTC().Format(time.RFC3339),
+			"hosts":              hosts,
+		},
+	}, nil
+}
+
+// pathConfigRollbackCertWrite restores the client certificate replaced by the
+// last rotation, after verifying it against the CCP Web Service. The current
+// client certificate becomes the previous certificate, so a rollback can be
+// undone.
+func (b *backend) pathConfigRollbackCertWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configLock.Lock()
+	defer b.configLock.Unlock()
+
+	name := data.Get("name").(string)
+	config, err := getConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("unknown connection: %s", name), nil
+	}
+	if config.CertSource == certSourceIssuer {
+		return logical.ErrorResponse("the client certificate of connection %s is issued by its issuer", name), nil
+	}
+	if config.AuthMethod == authMethodNone {
+		return logical.ErrorResponse("connection %s uses auth_method %s", name, authMethodNone), nil
+	}
+	if len(config.PreviousClientCert) == 0 {
+		return logical.ErrorResponse("no previous client certificate to roll back to"), nil
+	}
+
+	config.ClientCert, config.PreviousClientCert = config.PreviousClientCert, config.ClientCert
+	config.ClientKey, config.PreviousClientKey = config.PreviousClientKey, config.ClientKey
+
+	expiry, err := config.clientCertExpiry()
+	if err != nil {
+		return logical.ErrorResponse("unable to parse the previous client_cert: %v", err), nil
+	}
+	if !time.Now().Before(expiry) {
+		return logical.ErrorResponse("the previous client certificate expired at %s", expiry.UTC().Format(time.RFC3339)), nil
+	}
+
+	conn, err := newConnection(config)
+	if err != nil {
+		return logical.ErrorResponse("unable to create the CCP client using the previous certificate: %v", err), nil
+	}
+	hosts, ok := verifyConnection(ctx, conn)
+	if !ok {
+		conn.close()
+		resp := logical.ErrorResponse("unable to verify the previous certificate against the CCP Web Service, the current certificate stays in use")
+		resp.Data["hosts"] = hosts
+		return logical.RespondWithStatusCode(resp, req, http.StatusBadRequest)
+	}
+	if err := putConfig(ctx, req.Storage, name, config); err != nil {
+		conn.close()
+		return nil, err
+	}
+	b.ResetConnection(name, conn)
+
+	var resp *logical.Response
+	if warning := conn.certExpiryWarning(time.Now()); len(warning) != 0 {
+		resp = &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("rolled back, but %s", warning))
+	}
+	return resp, nil
+}
+
+const confRotateCertHelpSyn = `
+Rotate the client certificate of a connection.
+`
+const confRotateCertHelpDesc = `
+This endpoint stages a new client certificate and key, verifies the staged
+certificate against the CCP Web Service and promotes it. The replaced
+certificate is kept, so it can be restored using the rollback-cert endpoint.
+If the verification fails, the certificate remains staged and the current
+certificate stays in use. A staged certificate is promoted by writing to this
+endpoint without a certificate.
+`
+
+const confRollbackCertHelpSyn = `
+Roll back the client certificate of a connection.
+`
+const confRollbackCertHelpDesc = `
+This endpoint restores the client certificate replaced by the last rotation.
+The previous certificate is verified against the CCP Web Service first. An
+expired certificate is not restored.
+`
@@ -0,0 +1,154 @@
+package ccpsecrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// testClientCertificate returns a PEM encoded self-signed client certificate
+// and key, which expires at notAfter
+func testClientCertificate(t *testing.T, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "vault"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestConfigRotateCert(t *testing.T) {
+	ctx := context.Background()
+	b, s := testBackend(t)
+	host, rootCA := testTLSServer(t)
+
+	current, currentKey := testClientCertificate(t, time.Now().Add(time.Hour))
+	testRequest(t, b, s, logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":              host,
+		"application_id":    "MyApp",
+		"root_ca":           string(rootCA),
+		"client_cert":       current,
+		"client_key":        currentKey,
+		"verify_connection": false,
+	})
+
+	// A verified certificate is promoted, the replaced certificate is kept
+	rotated, rotatedKey := testClientCertificate(t, time.Now().Add(2*time.Hour))
+	testRequest(t, b, s, logical.UpdateOperation, "config/default/rotate-cert", map[string]interface{}{
+		"client_cert": rotated,
+		"client_key":  rotatedKey,
+	})
+	config, err := getConfig(ctx, s, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(config.ClientCert) != rotated || string(config.PreviousClientCert) != current {
+		t.Fatal("expected the rotated certificate to be promoted")
+	}
+
+	// The rollback restores the previous certificate
+	testRequest(t, b, s, logical.UpdateOperation, "config/default/rollback-cert", nil)
+	config, err = getConfig(ctx, s, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(config.ClientCert) != current || string(config.PreviousClientCert) != rotated {
+		t.Fatal("expected the previous certificate to be restored")
+	}
+}
+
+func TestConfigRollbackCert(t *testing.T) {
+	ctx := context.Background()
+	b, s := testBackend(t)
+	host, rootCA := testTLSServer(t)
+
+	current, currentKey := testClientCertificate(t, time.Now().Add(time.Hour))
+	testRequest(t, b, s, logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":              host,
+		"application_id":    "MyApp",
+		"root_ca":           string(rootCA),
+		"client_cert":       current,
+		"client_key":        currentKey,
+		"verify_connection": false,
+	})
+
+	rollback := func() *logical.Response {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/default/rollback-cert",
+			Storage:   s,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	setPrevious := func(cert, key string, hosts ...string) {
+		config, err := getConfig(ctx, s, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.PreviousClientCert, config.PreviousClientKey = []byte(cert), []byte(key)
+		if len(hosts) != 0 {
+			config.Hosts = hosts
+		}
+		if err := putConfig(ctx, s, "default", config); err != nil {
+			t.Fatal(err)
+		}
+		b.(*backend).ResetConnection("default", nil)
+	}
+
+	// An expired certificate is not restored
+	expired, expiredKey := testClientCertificate(t, time.Now().Add(-time.Hour))
+	setPrevious(expired, expiredKey)
+	if resp := rollback(); !resp.IsError() || !strings.Contains(resp.Error().Error(), "expired") {
+		t.Fatalf("got %v: want an error for an expired certificate", resp)
+	}
+
+	// A certificate failing the verification is not restored
+	stopped := httptest.NewUnstartedServer(http.NotFoundHandler())
+	down := stopped.Listener.Addr().String()
+	stopped.Listener.Close()
+	previous, previousKey := testClientCertificate(t, time.Now().Add(time.Hour))
+	setPrevious(previous, previousKey, down)
+	resp := rollback()
+	if resp.Data[logical.HTTPStatusCode] != http.StatusBadRequest || !strings.Contains(resp.Data[logical.HTTPRawBody].(string), `"hosts"`) {
+		t.Fatalf("got %v: want a verification error with the hosts", resp)
+	}
+
+	config, err := getConfig(ctx, s, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(config.ClientCert, []byte(current)) {
+		t.Fatal("expected the current certificate to stay in use")
+	}
+}
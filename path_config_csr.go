@@ -0,0 +1,368 @@
+package ccpsecrets
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// csrEntry is the key pair generated for a certificate signing request
+type csrEntry struct {
+	// The PEM encoded PKCS #8 private key
+	PrivateKey []byte `json:"private_key"`
+	// The PEM encoded certificate signing request
+	CSR []byte `json:"csr"`
+}
+
+// csrKey returns the storage key of the CSR of the named connection
+func csrKey(name string) string {
+	return csrPath + "/" + name
+}
+
+// generateCSRResponseFields are the fields of a CSR generation
+var generateCSRResponseFields = map[string]*framework.FieldSchema{
+	"csr": {
+		Type:        framework.TypeString,
+		Description: `The PEM encoded certificate signing request.`,
+	},
+}
+
+// pathConfigGenerateCSR returns the path configuration to generate a client
+// key and CSR for a connection
+func pathConfigGenerateCSR(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "/" + framework.GenericNameRegex("name") + "/generate-csr$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationVerb:   "generate",
+			OperationSuffix: "csr",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection.`,
+				Required:    true,
+			},
+			"key_type": {
+				Type:        framework.TypeString,
+				Description: `The type of the key to generate: rsa or ec.`,
+				Default:     "rsa",
+			},
+			"key_bits": {
+				Type:        framework.TypeInt,
+				Description: `The number of bits of the key. Defaults to 2048 for rsa and 256 for ec.`,
+			},
+			"common_name": {
+				Type:        framework.TypeString,
+				Description: `The common name of the subject of the CSR.`,
+				Required:    true,
+			},
+			"organization": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `The organization of the subject of the CSR.`,
+			},
+			"ou": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `The organizational unit of the subject of the CSR.`,
+			},
+			"alt_names": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `The DNS subject alternative names of the CSR.`,
+			},
+			"ip_sans": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `The IP subject alternative names of the CSR.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:  b.pathConfigGenerateCSRWrite,
+				Responses: okResponses(generateCSRResponseFields),
+			},
+		},
+
+		HelpSynopsis:    confGenerateCSRHelpSyn,
+		HelpDescription: confGenerateCSRHelpDesc,
+	}
+}
+
+// pathConfigSetSignedCert returns the path configuration to set the signed
+// certificate of a generated CSR
+func pathConfigSetSignedCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "/" + framework.GenericNameRegex("name") + "/set-signed-cert$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationVerb:   "set",
+			OperationSuffix: "signed-certificate",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection.`,
+				Required:    true,
+			},
+			"client_cert": {
+				Type:        framework.TypeString,
+				Description: `The PEM encoded certificate signed using the generated CSR.`,
+				Required:    true,
+			},
+			"verify_connection": {
+				Type:        framework.TypeBool,
+				Description: `Verify the connection to the CCP Web Service before the certificate is saved`,
+				Default:     true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:  b.pathConfigSetSignedCertWrite,
+				Responses: noContentResponses,
+			},
+		},
+
+		HelpSynopsis:    confSetSignedCertHelpSyn,
+		HelpDescription: confSetSignedCertHelpDesc,
+	}
+}
+
+// pathConfigGenerateCSRWrite generates a key pair and a CSR. The private key
+// is stored and never returned.
+func (b *backend) pathConfigGenerateCSRWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	config, err := getConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("unknown connection: %s", name), nil
+	}
+
+	commonName := data.Get("common_name").(string)
+	if len(commonName) == 0 {
+		return logical.ErrorResponse("no common_name provided"), nil
+	}
+	var ips []net.IP
+	for _, s := range data.Get("ip_sans").([]string) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return logical.ErrorResponse("invalid ip_sans: %s", s), nil
+		}
+		ips = append(ips, ip)
+	}
+
+	key, err := generateKey(data.Get("key_type").(string), data.Get("key_bits").(int))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:         commonName,
+			Organization:       data.Get("organization").([]string),
+			OrganizationalUnit: data.Get("ou").([]string),
+		},
+		DNSNames:    data.Get("alt_names").([]string),
+		IPAddresses: ips,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &csrEntry{
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		CSR:        pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+	}
+	se, err := logical.StorageEntryJSON(csrKey(name), entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, se); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"csr": string(entry.CSR),
+		},
+	}, nil
+}
+
+// pathConfigSetSignedCertWrite sets the signed certificate and the generated
+// key as the client certificate of the connection.
+func (b *backend) pathConfigSetSignedCertWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.configLock.Lock()
+	defer b.configLock.Unlock()
+
+	name := data.Get("name").(string)
+	config, err := getConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("unknown connection: %s", name), nil
+	}
+	if config.CertSource == certSourceIssuer {
+		return logical.ErrorResponse("the client certificate of connection %s is issued by its issuer", name), nil
+	}
+	if config.AuthMethod == authMethodNone {
+		return logical.ErrorResponse("connection %s uses auth_method %s", name, authMethodNone), nil
+	}
+
+	se, err := req.Storage.Get(ctx, csrKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if se == nil {
+		return logical.ErrorResponse("no CSR generated, use generate-csr first"), nil
+	}
+	entry := &csrEntry{}
+	if err := se.DecodeJSON(entry); err != nil {
+		return nil, err
+	}
+
+	clientCert := []byte(data.Get("client_cert").(string))
+	if err := matchPrivateKey(clientCert, entry.PrivateKey); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	expiry, err := (&clientConfig{ClientCert: clientCert}).clientCertExpiry()
+	if err != nil {
+		return logical.ErrorResponse("unable to parse client_cert: %v", err), nil
+	}
+	if !time.Now().Before(expiry) {
+		return logical.ErrorResponse("the client certificate expired at %s", expiry.UTC().Format(time.RFC3339)), nil
+	}
+
+	config.PreviousClientCert, config.PreviousClientKey = config.ClientCert, config.ClientKey
+	config.ClientCert, config.ClientKey = clientCert, entry.PrivateKey
+
+	conn, err := newConnection(config)
+	if err != nil {
+		return logical.ErrorResponse("unable to create the CCP client: %v", err), nil
+	}
+	if data.Get("verify_connection").(bool) {
+		hosts, ok := verifyConnection(ctx, conn)
+		if !ok {
+			conn.close()
+			resp := logical.ErrorResponse("unable to verify the connection to the CCP Web Service using the signed certificate")
+			resp.Data["hosts"] = hosts
+			return resp, nil
+		}
+	}
+
+	if err := putConfig(ctx, req.Storage, name, config); err != nil {
+		conn.close()
+		return nil, err
+	}
+	b.ResetConnection(name, conn)
+
+	if err := req.Storage.Delete(ctx, csrKey(name)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// generateKey generates a private key of the key type
+func generateKey(keyType string, keyBits int) (crypto.Signer, error) {
+	switch keyType {
+	case "rsa":
+		if keyBits == 0 {
+			keyBits = 2048
+		}
+		if keyBits < 2048 {
+			return nil, errors.New("key_bits must be at least 2048 for rsa")
+		}
+		return rsa.GenerateKey(rand.Reader, keyBits)
+	case "ec":
+		var curve elliptic.Curve
+		switch keyBits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("key_bits must be 256, 384 or 521 for ec")
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	default:
+		return nil, fmt.Errorf("invalid key_type %q: use rsa or ec", keyType)
+	}
+}
+
+// matchPrivateKey verifies that the public key of the PEM encoded certificate
+// belongs to the PEM encoded private key.
+func matchPrivateKey(certPEM, keyPEM []byte) error {
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return fmt.Errorf("unable to parse client_cert: %w", err)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return errors.New("unable to decode the generated private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return errors.New("the generated private key is not a signing key")
+	}
+
+	public, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return err
+	}
+	certPublic, err := x509.MarshalPKIXPublicKey(certs[0].PublicKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(public, certPublic) {
+		return errors.New("the client_cert does not match the generated private key")
+	}
+	return nil
+}
+
+const confGenerateCSRHelpSyn = `
+Generate a client key and CSR for a connection.
+`
+const confGenerateCSRHelpDesc = `
+This endpoint generates an RSA or EC private key and returns a certificate
+signing request for the key. The private key is stored seal wrapped and never
+leaves Vault. Set the signed certificate using the set-signed-cert endpoint.
+Generating a new CSR replaces a previously generated key.
+
+To create a connection without an existing certificate, write the connection
+with auth_method mtls and without client_cert, generate the CSR and set the
+signed certificate. The connection is pending until then.
+`
+
+const confSetSignedCertHelpSyn = `
+Set the signed certificate of a generated CSR as the client certificate.
+`
+const confSetSignedCertHelpDesc = `
+This endpoint accepts the certificate signed using the CSR of generate-csr.
+The certificate must match the generated private key. The certificate and key
+become the client certificate of the connection, the replaced certificate can
+be restored using the rollback-cert endpoint.
+`
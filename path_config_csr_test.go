@@ -0,0 +1,142 @@
+package ccpsecrets
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestConfigGenerateCSR(t *testing.T) {
+	b, s := testBackend(t)
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		return testRequest(t, b, s, op, path, data)
+	}
+
+	request(logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":              "ccp.example.com",
+		"application_id":    "MyApp",
+		"verify_connection": false,
+	})
+	resp := request(logical.UpdateOperation, "config/default/generate-csr", map[string]interface{}{
+		"key_type":    "ec",
+		"common_name": "vault.example.com",
+	})
+
+	certPEM := testSignCSR(t, resp.Data["csr"].(string), "vault.example.com")
+
+	request(logical.UpdateOperation, "config/default/set-signed-cert", map[string]interface{}{
+		"client_cert":       certPEM,
+		"verify_connection": false,
+	})
+
+	resp = request(logical.ReadOperation, "config/default", nil)
+	if resp.Data["client_cert_expiry"] == "" {
+		t.Fatal("expected the signed certificate to be set")
+	}
+}
+
+// testSignCSR checks the common name of the CSR and signs it using a self
+// signed CA. The PEM encoded certificate is returned.
+func testSignCSR(t *testing.T, csrPEM, commonName string) string {
+	t.Helper()
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		t.Fatal("unable to decode the CSR")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csr.Subject.CommonName != commonName {
+		t.Fatalf("got %v: want %s", csr.Subject.CommonName, commonName)
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      csr.Subject,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, csr.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestConfigPendingCSR(t *testing.T) {
+	b, s := testBackend(t)
+
+	// A connection using mtls without a certificate is pending
+	resp := testRequest(t, b, s, logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":           "ccp.example.com",
+		"application_id": "MyApp",
+		"auth_method":    "mtls",
+	})
+	if resp == nil || len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "pending") {
+		t.Fatalf("got %v: want a warning for the pending connection", resp)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "object/MySafe/MyObject",
+		Storage:   s,
+	})
+	if err != nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "pending") {
+		t.Fatalf("got %v, %v: want an error for the pending connection", resp, err)
+	}
+
+	// The signed certificate of the CSR completes the connection
+	resp = testRequest(t, b, s, logical.UpdateOperation, "config/default/generate-csr", map[string]interface{}{
+		"key_type":    "ec",
+		"common_name": "vault",
+	})
+	testRequest(t, b, s, logical.UpdateOperation, "config/default/set-signed-cert", map[string]interface{}{
+		"client_cert":       testSignCSR(t, resp.Data["csr"].(string), "vault"),
+		"verify_connection": false,
+	})
+
+	if _, err := b.(*backend).Connection(context.Background(), s, "default"); err != nil {
+		t.Fatalf("got %v: want the connection usable", err)
+	}
+
+	// Without the pending state, mtls requires a certificate
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config/other",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"host":           "ccp.example.com",
+			"application_id": "MyApp",
+			"auth_method":    "mtls",
+			"client_cert":    "cert",
+		},
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("got %v, %v: want an error for a client_cert without client_key", resp, err)
+	}
+}
@@ -0,0 +1,179 @@
+package ccpsecrets
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// issuerResponseFields are the fields of an issuer read
+var issuerResponseFields = map[string]*framework.FieldSchema{
+	"ca_cert_info": {
+		Type:        framework.TypeSlice,
+		Description: `The details of the CA certificate chain.`,
+	},
+	"common_name": {
+		Type:        framework.TypeString,
+		Description: `The common name of the issued client certificates.`,
+	},
+	"ttl": {
+		Type:        framework.TypeInt,
+		Description: `The number of seconds an issued client certificate is valid.`,
+	},
+	"reissue_before": {
+		Type:        framework.TypeInt,
+		Description: `The number of seconds before its expiry a client certificate is reissued.`,
+	},
+}
+
+// pathConfigIssuer returns the path configuration for the issuer of the client
+// certificates of a connection
+func pathConfigIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "/" + framework.GenericNameRegex("name") + "/issuer$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationSuffix: "issuer",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection.`,
+				Required:    true,
+			},
+			"ca_cert": {
+				Type:        framework.TypeString,
+				Description: `The PEM encoded CA certificate signing the client certificates.`,
+				Required:    true,
+			},
+			"ca_key": {
+				Type:        framework.TypeString,
+				Description: `The PEM encoded CA key signing the client certificates.`,
+				Required:    true,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+			"common_name": {
+				Type:        framework.TypeString,
+				Description: `The common name of the issued client certificates.`,
+				Required:    true,
+			},
+			"ttl": {
+				Type:        framework.TypeInt,
+				Description: `The number of seconds an issued client certificate is valid.`,
+				Default:     3600,
+			},
+			"reissue_before": {
+				Type:        framework.TypeInt,
+				Description: `The number of seconds before its expiry a client certificate is reissued.`,
+				Default:     900,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:  b.pathConfigIssuerWrite,
+				Responses: noContentResponses,
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:  b.pathConfigIssuerRead,
+				Responses: okResponses(issuerResponseFields),
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback:  b.pathConfigIssuerDelete,
+				Responses: noContentResponses,
+			},
+		},
+
+		HelpSynopsis:    confIssuerHelpSyn,
+		HelpDescription: confIssuerHelpDesc,
+	}
+}
+
+// pathConfigIssuerRead handles read commands to the issuer
+func (b *backend) pathConfigIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, err := getIssuer(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	caCertInfo, err := certificatesInfo(issuer.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ca_cert: %w", err)
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"ca_cert_info":   caCertInfo,
+			"common_name":    issuer.CommonName,
+			"ttl":            issuer.TTL,
+			"reissue_before": issuer.ReissueBefore,
+		},
+	}
+	return resp, nil
+}
+
+// pathConfigIssuerWrite handles update commands to the issuer
+func (b *backend) pathConfigIssuerWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	issuer := &issuerEntry{
+		CACert:        []byte(data.Get("ca_cert").(string)),
+		CAKey:         []byte(data.Get("ca_key").(string)),
+		CommonName:    data.Get("common_name").(string),
+		TTL:           data.Get("ttl").(int),
+		ReissueBefore: data.Get("reissue_before").(int),
+	}
+	if len(issuer.CommonName) == 0 {
+		return logical.ErrorResponse("no common_name provided"), nil
+	}
+	if issuer.TTL <= 0 {
+		return logical.ErrorResponse("ttl must be positive"), nil
+	}
+	if issuer.ReissueBefore < 0 || issuer.ReissueBefore >= issuer.TTL {
+		return logical.ErrorResponse("reissue_before must be positive and less than ttl"), nil
+	}
+	if _, err := issuer.signer(); err != nil {
+		return logical.ErrorResponse("unable to load the issuer: %v", err), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(issuerKey(name), issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	b.ResetConnection(name, nil)
+	return nil, nil
+}
+
+// pathConfigIssuerDelete handles delete commands to the issuer
+func (b *backend) pathConfigIssuerDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if err := req.Storage.Delete(ctx, issuerKey(name)); err != nil {
+		return nil, err
+	}
+
+	b.ResetConnection(name, nil)
+	return nil, nil
+}
+
+const confIssuerHelpSyn = `
+Configure the issuer of short-lived client certificates for a connection.
+`
+const confIssuerHelpDesc = `
+This endpoint configures the CA certificate and key used to issue short-lived
+client certificates for a connection using cert_source "issuer". The CA key is
+stored seal wrapped. Client certificates are issued in memory and reissued
+before they expire, replacing the CCP client in place. If a reissue fails, the
+current certificate is used until it expires and the reissue is retried.
+
+Client certificates are signed by this CA only. Signing by a Vault PKI mount
+is not supported, because a plugin can not call other mounts.
+`
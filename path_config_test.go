@@ -0,0 +1,124 @@
+package ccpsecrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestConfigPartialUpdate(t *testing.T) {
+	b, s := testBackend(t)
+
+	testRequest(t, b, s, logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":               "ccp1.example.com,ccp2.example.com",
+		"application_id":     "MyApp",
+		"connection_timeout": 10,
+		"verify_safe":        "MySafe",
+		"verify_object":      "MyObject",
+		"verify_connection":  false,
+	})
+
+	// An update only changes the provided fields
+	testRequest(t, b, s, logical.UpdateOperation, "config/default", map[string]interface{}{
+		"connection_timeout": 20,
+		"verify_connection":  false,
+	})
+	resp := testRequest(t, b, s, logical.ReadOperation, "config/default", nil)
+	if got := resp.Data["connection_timeout"]; got != 20 {
+		t.Fatalf("got connection_timeout %v: want 20", got)
+	}
+	if got := resp.Data["host"].([]string); len(got) != 2 {
+		t.Fatalf("got host %v: want both hosts kept", got)
+	}
+	if got := resp.Data["application_id"]; got != "MyApp" {
+		t.Fatalf("got application_id %v: want MyApp", got)
+	}
+	if got := resp.Data["verify_safe"]; got != "MySafe" {
+		t.Fatalf("got verify_safe %v: want MySafe", got)
+	}
+
+	// An empty value clears a field
+	testRequest(t, b, s, logical.UpdateOperation, "config/default", map[string]interface{}{
+		"verify_safe":       "",
+		"verify_object":     "",
+		"verify_connection": false,
+	})
+	resp = testRequest(t, b, s, logical.ReadOperation, "config/default", nil)
+	if got := resp.Data["verify_safe"]; got != "" {
+		t.Fatalf("got verify_safe %v: want cleared", got)
+	}
+	if got := resp.Data["connection_timeout"]; got != 20 {
+		t.Fatalf("got connection_timeout %v: want 20", got)
+	}
+
+	// Required fields can not be cleared
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/default",
+		Data:      map[string]interface{}{"application_id": ""},
+		Storage:   s,
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected an error clearing application_id: %v %v", err, resp)
+	}
+
+	// A create requires the full set of fields
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "config/other",
+		Data:      map[string]interface{}{"host": "ccp.example.com"},
+		Storage:   s,
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected an error creating without application_id: %v %v", err, resp)
+	}
+}
+
+func TestConfigDelete(t *testing.T) {
+	b, s := testBackend(t)
+
+	testRequest(t, b, s, logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":              "ccp.example.com",
+		"application_id":    "MyApp",
+		"verify_connection": false,
+	})
+	testRequest(t, b, s, logical.UpdateOperation, "config/default/generate-csr", map[string]interface{}{
+		"key_type":    "ec",
+		"common_name": "vault",
+	})
+	testRequest(t, b, s, logical.UpdateOperation, "inventory/MySafe", map[string]interface{}{
+		"objects": "MyObject",
+	})
+	testRequest(t, b, s, logical.DeleteOperation, "config/default", nil)
+
+	for _, prefix := range []string{configPath + "/", csrPath + "/", cachePath + "/", inventoryPath + "/"} {
+		keys, err := s.List(context.Background(), prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 0 {
+			t.Fatalf("got %v: want no keys left under %s", keys, prefix)
+		}
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "object/MySafe/MyObject",
+		Storage:   s,
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected an error response for a deleted connection: %v %v", err, resp)
+	}
+
+	// A recreated connection starts without inventory
+	testRequest(t, b, s, logical.CreateOperation, "config/default", map[string]interface{}{
+		"host":              "ccp.example.com",
+		"application_id":    "MyApp",
+		"verify_connection": false,
+	})
+	resp = testRequest(t, b, s, logical.ListOperation, "inventory/", nil)
+	if keys := resp.Data["keys"]; keys != nil {
+		t.Fatalf("got %v: want no inventory", keys)
+	}
+}
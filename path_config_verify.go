@@ -0,0 +1,224 @@
+package ccpsecrets
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	ccp "github.com/liviusnl/go-ccp"
+)
+
+// defaultVerifyTimeout is used for the TLS handshake, if the connection has no
+// connection_timeout
+const defaultVerifyTimeout = 30 * time.Second
+
+// verifyResponseFields are the fields of a connection verification
+var verifyResponseFields = map[string]*framework.FieldSchema{
+	"verified": {
+		Type:        framework.TypeBool,
+		Description: `Whether at least one host passed the verification.`,
+	},
+	"hosts": {
+		Type:        framework.TypeSlice,
+		Description: `The verification result per host.`,
+	},
+}
+
+// pathConfigVerify returns the path configuration to verify a connection
+func pathConfigVerify(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "/" + framework.GenericNameRegex("name") + "/verify$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationVerb:   "verify",
+			OperationSuffix: "connection",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection.`,
+				Required:    true,
+			},
+			"verify_safe": {
+				Type:        framework.TypeString,
+				Description: `The Safe of the object requested to verify the connection. Overrides the verify_safe of the connection.`,
+			},
+			"verify_folder": {
+				Type:        framework.TypeString,
+				Description: `The folder of the object requested to verify the connection. Overrides the verify_folder of the connection.`,
+			},
+			"verify_object": {
+				Type:        framework.TypeString,
+				Description: `The object requested to verify the connection. Overrides the verify_object of the connection.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:  b.pathConfigVerifyRead,
+				Responses: okResponses(verifyResponseFields),
+			},
+		},
+
+		HelpSynopsis:    confVerifyHelpSyn,
+		HelpDescription: confVerifyHelpDesc,
+	}
+}
+
+// pathConfigVerifyRead verifies the connectivity of every host of a
+// connection. The clients and the client certificate of the connection in use
+// are verified, an issued client certificate is not reissued.
+func (b *backend) pathConfigVerifyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	conn, err := b.Connection(ctx, req.Storage, name)
+	if errors.Is(err, errUnknownConnection) {
+		return logical.ErrorResponse("unknown connection: %s", name), nil
+	}
+	if err != nil {
+		return logical.ErrorResponse("unable to create the CCP client: %v", err), nil
+	}
+
+	// The verify object is overridden without changing the connection
+	config := *conn.config
+	if safe, ok := data.GetOk("verify_safe"); ok {
+		config.VerifySafe = safe.(string)
+	}
+	if folder, ok := data.GetOk("verify_folder"); ok {
+		config.VerifyFolder = folder.(string)
+	}
+	if object, ok := data.GetOk("verify_object"); ok {
+		config.VerifyObject = object.(string)
+	}
+
+	hosts, ok := verifyConnection(ctx, &ccpConnection{
+		config: &config,
+		hosts:  conn.hosts,
+	})
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"verified": ok,
+			"hosts":    hosts,
+		},
+	}, nil
+}
+
+// verifyConnection performs a TLS handshake with every host of the
+// connection, followed by a request for the verify object if set. The
+// connection is verified if at least one host passed.
+func verifyConnection(ctx context.Context, conn *ccpConnection) ([]map[string]interface{}, bool) {
+	var verified bool
+	hosts := make([]map[string]interface{}, 0, len(conn.hosts))
+	for _, h := range conn.hosts {
+		result := verifyHost(ctx, conn.config, h)
+		verified = verified || result["verified"].(bool)
+		hosts = append(hosts, result)
+	}
+	return hosts, verified
+}
+
+// verifyHost verifies the connectivity of a single host
+func verifyHost(ctx context.Context, config *clientConfig, h *ccpHost) map[string]interface{} {
+	result := map[string]interface{}{
+		"host":     h.host,
+		"verified": false,
+	}
+
+	tlsInfo, err := verifyTLS(ctx, config, h.host)
+	if err != nil {
+		result["error"] = fmt.Sprintf("TLS handshake failed: %v", err)
+		return result
+	}
+	result["tls"] = tlsInfo
+
+	if len(config.VerifyObject) == 0 {
+		result["verified"] = true
+		return result
+	}
+
+	start := time.Now()
+	cresp, err := requestHost(ctx, h.client, &credentialRequest{
+		Request: ccp.PasswordRequest{
+			Safe:   config.VerifySafe,
+			Folder: config.VerifyFolder,
+			Object: config.VerifyObject,
+			Reason: "Vault connection verification",
+		},
+	})
+	result["request_latency_ms"] = time.Since(start).Milliseconds()
+	switch {
+	case err != nil:
+		result["error"] = fmt.Sprintf("request failed: %v", err)
+	case len(cresp.LogicalError) != 0:
+		result["error"] = cresp.LogicalError
+		result["ccp_error_code"] = ccpErrorCode(cresp.LogicalError)
+	default:
+		result["verified"] = true
+	}
+	return result
+}
+
+// verifyTLS performs a TLS handshake with the host, using the certificates of
+// the config, and returns the details of the TLS connection.
+func verifyTLS(ctx context.Context, config *clientConfig, host string) (map[string]interface{}, error) {
+	cert, rootCAs, err := config.certificates()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates:       []tls.Certificate{*cert},
+		RootCAs:            rootCAs,
+		InsecureSkipVerify: config.SkipTLSVerify,
+	}
+	if config.EnableTLSRenegotiation {
+		tlsConfig.Renegotiation = tls.RenegotiateOnceAsClient
+	}
+
+	addr := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		addr = net.JoinHostPort(host, "443")
+	}
+	timeout := defaultVerifyTimeout
+	if config.ConnectionTimeout > 0 {
+		timeout = time.Duration(config.ConnectionTimeout) * time.Second
+	}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    tlsConfig,
+	}
+
+	start := time.Now()
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	latency := time.Since(start)
+
+	state := c.(*tls.Conn).ConnectionState()
+	info := map[string]interface{}{
+		"version":              tls.VersionName(state.Version),
+		"cipher_suite":         tls.CipherSuiteName(state.CipherSuite),
+		"handshake_latency_ms": latency.Milliseconds(),
+	}
+	if len(state.PeerCertificates) != 0 {
+		peer := state.PeerCertificates[0]
+		info["server_subject"] = peer.Subject.String()
+		info["server_issuer"] = peer.Issuer.String()
+		info["server_not_after"] = peer.NotAfter.UTC().Format(time.RFC3339)
+	}
+	return info, nil
+}
+
+const confVerifyHelpSyn = `
+Verify the connectivity of a connection to the CCP Web Service.
+`
+const confVerifyHelpDesc = `
+This endpoint performs a TLS handshake with every host of the connection and
+requests the verify object, if configured. The details of the TLS connection,
+the latency and the CCP error code on failure are reported per host. The
+connection is verified with the client certificate in use.
+`
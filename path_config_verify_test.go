@@ -0,0 +1,82 @@
+package ccpsecrets
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testTLSServer starts a TLS server and returns its host and the PEM encoded
+// certificate
+func testTLSServer(t *testing.T) (string, []byte) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(ts.Close)
+
+	rootCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	return ts.Listener.Addr().String(), rootCA
+}
+
+func TestVerifyHost(t *testing.T) {
+	ctx := context.Background()
+	host, rootCA := testTLSServer(t)
+
+	conn, err := newConnection(&clientConfig{Hosts: []string{host}, RootCA: rootCA, ApplicationID: "MyApp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+
+	result := verifyHost(ctx, conn.config, conn.hosts[0])
+	if result["verified"] != true {
+		t.Fatalf("got %v: want the host verified", result)
+	}
+	tlsInfo := result["tls"].(map[string]interface{})
+	if _, ok := tlsInfo["version"]; !ok {
+		t.Fatalf("got %v: want the TLS version", tlsInfo)
+	}
+
+	// Without the root CA, the certificate of the server is not trusted
+	untrusted, err := newConnection(&clientConfig{Hosts: []string{host}, ApplicationID: "MyApp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer untrusted.close()
+
+	result = verifyHost(ctx, untrusted.config, untrusted.hosts[0])
+	if result["verified"] != false || !strings.Contains(result["error"].(string), "TLS handshake failed") {
+		t.Fatalf("got %v: want the handshake to fail", result)
+	}
+}
+
+func TestVerifyConnection(t *testing.T) {
+	ctx := context.Background()
+	host, rootCA := testTLSServer(t)
+
+	// A host without a listening server
+	stopped := httptest.NewUnstartedServer(http.NotFoundHandler())
+	down := stopped.Listener.Addr().String()
+	stopped.Listener.Close()
+
+	conn, err := newConnection(&clientConfig{Hosts: []string{down, host}, RootCA: rootCA, ApplicationID: "MyApp", ConnectionTimeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+
+	// The connection is verified if one host passed
+	hosts, ok := verifyConnection(ctx, conn)
+	if !ok || len(hosts) != 2 {
+		t.Fatalf("got %v, %v: want the connection verified with 2 results", hosts, ok)
+	}
+	if hosts[0]["verified"] != false || hosts[1]["verified"] != true {
+		t.Fatalf("got %v: want only the second host verified", hosts)
+	}
+
+	conn.hosts = conn.hosts[:1]
+	if _, ok := verifyConnection(ctx, conn); ok {
+		t.Fatal("expected the connection to fail without a reachable host")
+	}
+}
@@ -0,0 +1,204 @@
+package ccpsecrets
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	ccp "github.com/liviusnl/go-ccp"
+)
+
+// pathCreds retrieves the credentials bound by a role
+func pathCreds(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: credsPath + "/" + framework.GenericNameRegex("name"),
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationSuffix: "credentials",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the role.`,
+				Required:    true,
+			},
+			"safe": {
+				Type:        framework.TypeString,
+				Description: `The name of the Safe where the secret is stored. Optional if the role allows a single Safe.`,
+				Query:       true,
+			},
+			"folder": {
+				Type:        framework.TypeString,
+				Description: `The name of the folder where the secret is stored.`,
+				Query:       true,
+			},
+			"object": {
+				Type:        framework.TypeString,
+				Description: `The name of the secret object to retrieve. Optional if the role allows a single object.`,
+				Query:       true,
+			},
+			"format": {
+				Type:        framework.TypeString,
+				Description: `The name of the template rendering the response into the rendered field.`,
+				Query:       true,
+			},
+			"password_change_wait": passwordChangeWaitField,
+			"fields": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `The response fields to return. Must be allowed by the role. If not set, the fields of the role are returned.`,
+				Query:       true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:  b.pathCredsRead,
+				Responses: credentialResponses,
+			},
+		},
+
+		HelpSynopsis:    credsHelpSyn,
+		HelpDescription: credsHelpDesc,
+	}
+}
+
+// pathCredsRead executes the CCP request bound by a role
+func (b *backend) pathCredsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	role, err := getRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse("unknown role: %s", name), nil
+	}
+
+	cr, err := role.credentialRequest(
+		data.Get("safe").(string),
+		data.Get("folder").(string),
+		data.Get("object").(string),
+		data.Get("fields").([]string),
+	)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	cr.Template, err = formatTemplate(ctx, req.Storage, data.Get("format").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	cr.PasswordChangeWait, err = passwordChangeWaitOverride(data)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	cresp, err := b.retrieve(ctx, req.Storage, cr)
+	if err != nil {
+		return retrieveErrorResponse(err)
+	}
+
+	return b.leaseResponse(ctx, req, cr, cresp)
+}
+
+// credentialRequest builds the request for the safe, folder, object and
+// response fields, after verifying that these are allowed by the role. Empty
+// values default to the single value allowed by the role, and no fields to
+// the fields of the role.
+func (r *roleEntry) credentialRequest(safe, folder, object string, fields []string) (*credentialRequest, error) {
+	if len(safe) == 0 && len(r.Safes) == 1 {
+		safe = r.Safes[0]
+	}
+	if !slices.Contains(r.Safes, safe) {
+		return nil, fmt.Errorf("safe %q is not allowed by the role", safe)
+	}
+
+	if len(folder) == 0 && len(r.Folders) == 1 && !strings.ContainsAny(r.Folders[0], `*?[\`) {
+		folder = r.Folders[0]
+	}
+	if !r.allowsFolder(folder) {
+		return nil, fmt.Errorf("folder %q is not allowed by the role", folder)
+	}
+
+	if len(object) == 0 && len(r.Objects) == 1 {
+		object = r.Objects[0]
+	}
+	if (len(object) != 0 || len(r.Objects) != 0) && !slices.Contains(r.Objects, object) {
+		return nil, fmt.Errorf("object %q is not allowed by the role", object)
+	}
+
+	if len(fields) == 0 {
+		fields = r.Fields
+	}
+	if err := validateFields(fields); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if len(r.Fields) != 0 && !slices.Contains(r.Fields, field) {
+			return nil, fmt.Errorf("field %q is not allowed by the role", field)
+		}
+	}
+
+	qf, err := parseQueryFormat(r.QueryFormat)
+	if err != nil {
+		return nil, err
+	}
+	if qf == ccp.QueryFormatRegEx {
+		// Values allowed by a pattern of the role are matched literally by
+		// CCP, so they can not select objects outside the role
+		safe = literalValue(safe, r.Safes)
+		folder = literalValue(folder, r.Folders)
+		object = literalValue(object, r.Objects)
+	}
+
+	return &credentialRequest{
+		Connection:  r.Connection,
+		Query:       r.hasQuery(),
+		QueryFormat: qf,
+		Request: ccp.PasswordRequest{
+			Safe:     safe,
+			Folder:   folder,
+			Object:   object,
+			UserName: r.UserName,
+			Address:  r.Address,
+			Database: r.Database,
+			PolicyID: r.PolicyID,
+			Reason:   r.Reason,
+		},
+		Fields: fields,
+	}, nil
+}
+
+// allowsFolder returns true if the folder matches one of the folder patterns
+// of the role. The root folder is allowed if no folder patterns are set.
+func (r *roleEntry) allowsFolder(folder string) bool {
+	if len(r.Folders) == 0 {
+		return len(folder) == 0
+	}
+	for _, pattern := range r.Folders {
+		if ok, _ := path.Match(pattern, folder); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// literalValue returns the value quoted as a regular expression, unless it is
+// configured verbatim on the role
+func literalValue(value string, configured []string) string {
+	if slices.Contains(configured, value) {
+		return value
+	}
+	return regexp.QuoteMeta(value)
+}
+
+const credsHelpSyn = `
+Request the secret bound by a role from the CyberArk Credentials Provider
+`
+const credsHelpDesc = `
+This endpoint allows you to request the secret bound by a role via the
+CyberArk Credentials Provider Web Service. The Safe, folder and object can be
+selected, but must be allowed by the role.
+`
@@ -0,0 +1,118 @@
+package ccpsecrets
+
+import (
+	"testing"
+)
+
+func TestRoleCredentialRequest(t *testing.T) {
+	role := &roleEntry{
+		Connection: "default",
+		Safes:      []string{"MySafe", "OtherSafe"},
+		Folders:    []string{"Root/*"},
+		Objects:    []string{"MyObject"},
+		Reason:     "testing",
+	}
+
+	tests := []struct {
+		safe, folder, object string
+		wantErr              bool
+	}{
+		{"MySafe", "Root/MyFolder", "", false},
+		{"OtherSafe", "Root/MyFolder", "MyObject", false},
+		{"", "Root/MyFolder", "MyObject", true},
+		{"UnknownSafe", "Root/MyFolder", "MyObject", true},
+		{"MySafe", "", "MyObject", true},
+		{"MySafe", "Root/MyFolder/Nested", "MyObject", true},
+		{"MySafe", "Root/MyFolder", "OtherObject", true},
+	}
+	for _, tt := range tests {
+		cr, err := role.credentialRequest(tt.safe, tt.folder, tt.object, nil)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s/%s/%s: expected an error", tt.safe, tt.folder, tt.object)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s/%s/%s: unexpected error: %v", tt.safe, tt.folder, tt.object, err)
+			continue
+		}
+		if cr.Request.Object != "MyObject" || cr.Request.Reason != "testing" || cr.Query {
+			t.Errorf("%s/%s/%s: unexpected request: %+v", tt.safe, tt.folder, tt.object, cr)
+		}
+	}
+}
+
+func TestRoleRegexQueryFormat(t *testing.T) {
+	role := &roleEntry{
+		Connection:  "default",
+		Safes:       []string{"MySafe"},
+		Folders:     []string{"Team*"},
+		UserName:    "admin",
+		QueryFormat: "regex",
+	}
+
+	// A folder passing the glob is matched literally by CCP
+	cr, err := role.credentialRequest("", "Team.*|Other", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cr.Request.Folder != `Team\.\*\|Other` {
+		t.Fatalf("got folder %q: want the folder quoted", cr.Request.Folder)
+	}
+	if cr.Request.Safe != "MySafe" {
+		t.Fatalf("got safe %q: want the safe of the role", cr.Request.Safe)
+	}
+
+	// Without regex, the folder is sent as is
+	role.QueryFormat = "exact"
+	cr, err = role.credentialRequest("", "Team.Prod", "", nil)
+	if err != nil || cr.Request.Folder != "Team.Prod" {
+		t.Fatalf("got %v, %v: want the folder unchanged", cr, err)
+	}
+}
+
+func TestRoleFields(t *testing.T) {
+	role := &roleEntry{
+		Connection: "default",
+		Safes:      []string{"MySafe"},
+		Objects:    []string{"MyObject"},
+		Fields:     []string{"content", "user_name"},
+	}
+
+	cr, err := role.credentialRequest("", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cr.Fields) != 2 {
+		t.Fatalf("got fields %v: want the fields of the role", cr.Fields)
+	}
+	if _, err := role.credentialRequest("", "", "", []string{"content", "address"}); err == nil {
+		t.Fatal("expected an error for a field not allowed by the role")
+	}
+
+	data := map[string]interface{}{"content": "secret", "user_name": "admin", "address": "host"}
+	cr, err = role.credentialRequest("", "", "", []string{"content"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	projected := projectFields(data, cr.Fields)
+	if len(projected) != 1 || projected["content"] != "secret" {
+		t.Fatalf("got %v: want only content", projected)
+	}
+
+	// A field not set on the account is omitted
+	projected = projectFields(data, []string{"content", "database"})
+	if len(projected) != 1 {
+		t.Fatalf("got %v: want database omitted", projected)
+	}
+
+	// Unknown fields are rejected before the request
+	if _, err := role.credentialRequest("", "", "", []string{"contnet"}); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+	role.Fields = []string{"content", "usr_name"}
+	if err := role.validate(); err == nil {
+		t.Fatal("expected an error for an unknown field of the role")
+	}
+}
@@ -0,0 +1,247 @@
+package ccpsecrets
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// inventoryEntry contains the objects of a Safe which can be browsed. CCP has
+// no API to enumerate the accounts of a Safe, so the inventory is maintained
+// by the operator.
+type inventoryEntry struct {
+	// The objects of the Safe, prefixed by their folder: "folder/object" or
+	// "object" for the root folder
+	Objects []string `json:"objects"`
+}
+
+// inventoryKey returns the storage key of the inventory of the Safe
+func inventoryKey(connection, safe string) string {
+	return inventoryPath + "/" + connection + "/" + safe
+}
+
+// getInventory returns the inventory of the Safe, or nil if the Safe has no
+// inventory.
+func getInventory(ctx context.Context, s logical.Storage, connection, safe string) (*inventoryEntry, error) {
+	entry, err := s.Get(ctx, inventoryKey(connection, safe))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	inventory := &inventoryEntry{}
+	if err := entry.DecodeJSON(inventory); err != nil {
+		return nil, err
+	}
+	return inventory, nil
+}
+
+// deleteInventories removes the inventories of all Safes of the connection
+func deleteInventories(ctx context.Context, s logical.Storage, connection string) error {
+	safes, err := s.List(ctx, inventoryPath+"/"+connection+"/")
+	if err != nil {
+		return err
+	}
+	for _, safe := range safes {
+		if err := s.Delete(ctx, inventoryKey(connection, safe)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// list returns the objects and subfolders of the folder. Subfolders end in a
+// slash.
+func (i *inventoryEntry) list(folder string) []string {
+	prefix := ""
+	if len(folder) != 0 {
+		prefix = strings.Trim(folder, "/") + "/"
+	}
+
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, object := range i.Objects {
+		if !strings.HasPrefix(object, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(object, prefix)
+		if n := strings.Index(key, "/"); n != -1 {
+			key = key[:n+1]
+		}
+		if len(key) != 0 && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateInventoryObjects checks that the objects have a name and no empty
+// folders
+func validateInventoryObjects(objects []string) error {
+	for _, object := range objects {
+		if strings.HasSuffix(object, "/") || strings.HasPrefix(object, "/") || strings.Contains(object, "//") {
+			return errors.New("invalid object: " + object)
+		}
+	}
+	return nil
+}
+
+// inventoryResponseFields are the fields of an inventory read
+var inventoryResponseFields = map[string]*framework.FieldSchema{
+	"objects": {
+		Type:        framework.TypeStringSlice,
+		Description: `The objects of the Safe, prefixed by their folder.`,
+	},
+}
+
+// pathInventory returns the path configurations to maintain the inventories
+// of the Safes.
+func pathInventory(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: inventoryPath + "/?$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixCCP,
+				OperationSuffix: "inventories",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"connection": {
+					Type:        framework.TypeString,
+					Description: `The name of the connection to the CCP Web Service.`,
+					Default:     defaultConnection,
+					Query:       true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback:  b.pathInventoryList,
+					Responses: listResponses,
+				},
+			},
+
+			HelpSynopsis:    inventoryListHelpSyn,
+			HelpDescription: inventoryListHelpDesc,
+		},
+		{
+			Pattern: inventoryPath + "/(?P<safe>[^/]+)$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixCCP,
+				OperationSuffix: "inventory",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"safe": {
+					Type:        framework.TypeString,
+					Description: `The name of the Safe.`,
+					Required:    true,
+				},
+				"connection": {
+					Type:        framework.TypeString,
+					Description: `The name of the connection to the CCP Web Service.`,
+					Default:     defaultConnection,
+					Query:       true,
+				},
+				"objects": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: `The objects of the Safe, prefixed by their folder, e.g. "folder/object". Objects in the root folder have no prefix.`,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:  b.pathInventoryWrite,
+					Responses: noContentResponses,
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback:  b.pathInventoryRead,
+					Responses: okResponses(inventoryResponseFields),
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback:  b.pathInventoryDelete,
+					Responses: noContentResponses,
+				},
+			},
+
+			HelpSynopsis:    inventoryHelpSyn,
+			HelpDescription: inventoryHelpDesc,
+		},
+	}
+}
+
+// pathInventoryList handles list commands to the inventories
+func (b *backend) pathInventoryList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	safes, err := req.Storage.List(ctx, inventoryPath+"/"+data.Get("connection").(string)+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(safes), nil
+}
+
+// pathInventoryRead handles read commands to the inventory of a Safe
+func (b *backend) pathInventoryRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	inventory, err := getInventory(ctx, req.Storage, data.Get("connection").(string), data.Get("safe").(string))
+	if err != nil {
+		return nil, err
+	}
+	if inventory == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"objects": inventory.Objects,
+		},
+	}, nil
+}
+
+// pathInventoryWrite handles update commands to the inventory of a Safe
+func (b *backend) pathInventoryWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	objects := data.Get("objects").([]string)
+	if err := validateInventoryObjects(objects); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(inventoryKey(data.Get("connection").(string), data.Get("safe").(string)), &inventoryEntry{
+		Objects: objects,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// pathInventoryDelete handles delete commands to the inventory of a Safe
+func (b *backend) pathInventoryDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, inventoryKey(data.Get("connection").(string), data.Get("safe").(string))); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const inventoryListHelpSyn = `
+List the Safes with an inventory.
+`
+const inventoryListHelpDesc = `
+This endpoint lists the Safes of a connection with an inventory.
+`
+
+const inventoryHelpSyn = `
+Manage the inventory of the objects of a Safe.
+`
+const inventoryHelpDesc = `
+The CCP Web Service can not enumerate the accounts of a Safe. The inventory
+contains the objects of a Safe maintained by the operator, which are listed
+by the object endpoint so the Safe can be browsed.
+`
@@ -0,0 +1,43 @@
+package ccpsecrets
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestObjectList(t *testing.T) {
+	b, s := testBackend(t)
+
+	testRequest(t, b, s, logical.UpdateOperation, "inventory/MySafe", map[string]interface{}{
+		"objects": "root-object,Linux/server1,Linux/server2,Linux/Prod/server3",
+	})
+
+	for folder, want := range map[string][]string{
+		"":            {"Linux/", "root-object"},
+		"Linux/":      {"Prod/", "server1", "server2"},
+		"Linux/Prod/": {"server3"},
+	} {
+		resp := testRequest(t, b, s, logical.ListOperation, "object/MySafe/"+folder, nil)
+		if got := resp.Data["keys"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", folder, got, want)
+		}
+	}
+
+	resp := testRequest(t, b, s, logical.ListOperation, "inventory/", nil)
+	if got := resp.Data["keys"]; !reflect.DeepEqual(got, []string{"MySafe"}) {
+		t.Errorf("got %v: want [MySafe]", got)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "inventory/MySafe",
+		Data:      map[string]interface{}{"objects": "Linux/"},
+		Storage:   s,
+	})
+	if err != nil || !resp.IsError() {
+		t.Fatalf("expected an error for an object without a name: %v %v", err, resp)
+	}
+}
@@ -9,11 +9,16 @@ import (
 )
 
 const objectPathRegExp = objectPath + "/(?P<safe>[^/]+)/(?:(?P<folder>.*)/)?(?P<object>[^/]+)$"
+const objectListPathRegExp = objectPath + "/(?P<safe>[^/]+)/(?:(?P<folder>.*)/)?$"
 
 // pathObject executes a request operation against the CCP Web Service
 func pathObject(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: objectPathRegExp,
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationSuffix: "object|object-in-folder",
+		},
 		Fields: map[string]*framework.FieldSchema{
 			"safe": {
 				Type:        framework.TypeString,
@@ -30,11 +35,30 @@ func pathObject(b *backend) *framework.Path {
 			"reason": {
 				Type:        framework.TypeString,
 				Description: `The reason for retrieving the password.`,
+				Query:       true,
+			},
+			"format": {
+				Type:        framework.TypeString,
+				Description: `The name of the template rendering the response into the rendered field.`,
+				Query:       true,
+			},
+			"password_change_wait": passwordChangeWaitField,
+			"fields": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `The response fields to return, e.g. content,user_name. If not set, all fields are returned.`,
+				Query:       true,
+			},
+			"connection": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection to the CCP Web Service.`,
+				Default:     defaultConnection,
+				Query:       true,
 			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
-				Callback: b.pathObjectRead,
+				Callback:  b.pathObjectRead,
+				Responses: credentialResponses,
 			},
 		},
 
@@ -43,35 +67,88 @@ func pathObject(b *backend) *framework.Path {
 	}
 }
 
-// pathObjectRead executes a CCP Object request
-func (b *backend) pathObjectRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	client, err := b.Client(ctx, req.Storage)
-	if err != nil {
-		return nil, err
+// pathObjectList lists the objects of a Safe from its inventory
+func pathObjectList(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: objectListPathRegExp,
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationSuffix: "objects|objects-in-folder",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"safe": {
+				Type:        framework.TypeString,
+				Description: `The name of the Safe to list.`,
+			},
+			"folder": {
+				Type:        framework.TypeString,
+				Description: `The name of the folder to list.`,
+			},
+			"connection": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection to the CCP Web Service.`,
+				Default:     defaultConnection,
+				Query:       true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback:  b.pathObjectListRead,
+				Responses: listResponses,
+			},
+		},
+
+		HelpSynopsis:    objectListHelpSyn,
+		HelpDescription: objectListHelpDesc,
 	}
+}
 
-	r, logicalError, err := client.Request(ctx, &ccp.PasswordRequest{
-		Safe:   data.Get("safe").(string),
-		Folder: data.Get("folder").(string),
-		Object: data.Get("object").(string),
-		Reason: data.Get("reason").(string),
-	})
+// pathObjectListRead lists the objects and subfolders of a folder
+func (b *backend) pathObjectListRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	inventory, err := getInventory(ctx, req.Storage, data.Get("connection").(string), data.Get("safe").(string))
 	if err != nil {
 		return nil, err
 	}
-	if len(logicalError) != 0 {
-		return logical.ErrorResponse(logicalError), nil
+	if inventory == nil {
+		return nil, nil
 	}
 
-	mr, err := r.MapSnakeCase()
+	return logical.ListResponse(inventory.list(data.Get("folder").(string))), nil
+}
+
+// pathObjectRead executes a CCP Object request
+func (b *backend) pathObjectRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	fields := data.Get("fields").([]string)
+	if err := validateFields(fields); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	tmpl, err := formatTemplate(ctx, req.Storage, data.Get("format").(string))
 	if err != nil {
-		return nil, err
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	wait, err := passwordChangeWaitOverride(data)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	resp := &logical.Response{
-		Data: mr,
+	cr := &credentialRequest{
+		Connection: data.Get("connection").(string),
+		Request: ccp.PasswordRequest{
+			Safe:   data.Get("safe").(string),
+			Folder: data.Get("folder").(string),
+			Object: data.Get("object").(string),
+			Reason: data.Get("reason").(string),
+		},
+		Fields:             fields,
+		Template:           tmpl,
+		PasswordChangeWait: wait,
+	}
+	cresp, err := b.retrieve(ctx, req.Storage, cr)
+	if err != nil {
+		return retrieveErrorResponse(err)
 	}
-	return resp, nil
+
+	return b.leaseResponse(ctx, req, cr, cresp)
 }
 
 const objectHelpSyn = `
@@ -81,3 +158,12 @@ const objectHelpDesc = `
 This endpoint allows you to request via the CyberArk Credentials Provider
 Web Service secrets stored in the Enterprise Password Vault.
 `
+
+const objectListHelpSyn = `
+List the objects of a Safe.
+`
+const objectListHelpDesc = `
+This endpoint lists the objects and subfolders of a Safe or folder. As the
+CCP Web Service can not enumerate accounts, the objects are listed from the
+inventory of the Safe maintained using the inventory endpoint.
+`
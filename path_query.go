@@ -2,8 +2,6 @@ package ccpsecrets
 
 import (
 	"context"
-	"regexp"
-	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -14,47 +12,79 @@ import (
 func pathQuery(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: queryPath + "$",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationVerb:   "query",
+			OperationSuffix: "credentials",
+		},
 		Fields: map[string]*framework.FieldSchema{
 			"safe": {
 				Type:        framework.TypeString,
 				Description: `The name of the Safe where the secret is stored.`,
+				Query:       true,
 			},
 			"folder": {
 				Type:        framework.TypeString,
 				Description: ` the name of the folder where the secret is stored.`,
+				Query:       true,
 			},
 			"object": {
 				Type:        framework.TypeString,
 				Description: `The name of the secret object to retrieve.`,
+				Query:       true,
 			},
 			"username": {
 				Type:        framework.TypeString,
 				Description: `Search criteria according to the UserName account property.`,
+				Query:       true,
 			},
 			"address": {
 				Type:        framework.TypeString,
 				Description: `Search criteria according to the Address account property.`,
+				Query:       true,
 			},
 			"database": {
 				Type:        framework.TypeString,
 				Description: `Search criteria according to the Database account property.`,
+				Query:       true,
 			},
 			"policy_id": {
 				Type:        framework.TypeString,
 				Description: "The format that will be used in the setPolicyID method.",
+				Query:       true,
 			},
 			"reason": {
 				Type:        framework.TypeString,
 				Description: `The reason for retrieving the password.`,
+				Query:       true,
+			},
+			"format": {
+				Type:        framework.TypeString,
+				Description: `The name of the template rendering the response into the rendered field.`,
+				Query:       true,
+			},
+			"password_change_wait": passwordChangeWaitField,
+			"fields": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: `The response fields to return, e.g. content,user_name. If not set, all fields are returned.`,
+				Query:       true,
+			},
+			"connection": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection to the CCP Web Service.`,
+				Default:     defaultConnection,
+				Query:       true,
 			},
 			"query_format": {
 				Type:        framework.TypeString,
 				Description: `Defines the query format, which can optionally use regular expressions.`,
+				Query:       true,
 			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
-				Callback: b.pathQueryRead,
+				Callback:  b.pathQueryRead,
+				Responses: credentialResponses,
 			},
 		},
 
@@ -65,54 +95,47 @@ func pathQuery(b *backend) *framework.Path {
 
 // pathQueryRead executes a CCP Query request
 func (b *backend) pathQueryRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	client, err := b.Client(ctx, req.Storage)
+	qf, err := parseQueryFormat(data.Get("query_format").(string))
 	if err != nil {
-		return nil, err
+		return logical.ErrorResponse(err.Error()), nil
 	}
-
-	q := &ccp.PasswordRequest{
-		Safe:     data.Get("safe").(string),
-		Folder:   data.Get("folder").(string),
-		Object:   data.Get("object").(string),
-		UserName: data.Get("username").(string),
-		Address:  data.Get("address").(string),
-		Database: data.Get("database").(string),
-		PolicyID: data.Get("policy_id").(string),
-		Reason:   data.Get("reason").(string),
+	fields := data.Get("fields").([]string)
+	if err := validateFields(fields); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
-	qfs := data.Get("query_format").(string)
-	qf := ccp.QueryFormatExact
-	if len(qfs) != 0 {
-		re := regexp.MustCompile("^((?i)(?:exact)|(?:regex))$")
-		format := re.FindStringSubmatch(qfs)
-		if len(format) != 2 {
-			return logical.ErrorResponse("invalid query_format: use exact or regex"), nil
-		}
-		switch strings.ToLower(format[1]) {
-		case "exact":
-			qf = ccp.QueryFormatExact
-		case "regex":
-			qf = ccp.QueryFormatRegEx
-		}
-	}
-
-	r, logicalError, err := client.Query(ctx, q, qf)
+	tmpl, err := formatTemplate(ctx, req.Storage, data.Get("format").(string))
 	if err != nil {
-		return nil, err
+		return logical.ErrorResponse(err.Error()), nil
 	}
-	if len(logicalError) != 0 {
-		return logical.ErrorResponse(logicalError), nil
+	wait, err := passwordChangeWaitOverride(data)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	mr, err := r.MapSnakeCase()
+	cr := &credentialRequest{
+		Connection:  data.Get("connection").(string),
+		Query:       true,
+		QueryFormat: qf,
+		Request: ccp.PasswordRequest{
+			Safe:     data.Get("safe").(string),
+			Folder:   data.Get("folder").(string),
+			Object:   data.Get("object").(string),
+			UserName: data.Get("username").(string),
+			Address:  data.Get("address").(string),
+			Database: data.Get("database").(string),
+			PolicyID: data.Get("policy_id").(string),
+			Reason:   data.Get("reason").(string),
+		},
+		Fields:             fields,
+		Template:           tmpl,
+		PasswordChangeWait: wait,
+	}
+	cresp, err := b.retrieve(ctx, req.Storage, cr)
 	if err != nil {
-		return nil, err
+		return retrieveErrorResponse(err)
 	}
 
-	resp := &logical.Response{
-		Data: mr,
-	}
-	return resp, nil
+	return b.leaseResponse(ctx, req, cr, cresp)
 }
 
 const queryHelpSyn = `
@@ -0,0 +1,337 @@
+package ccpsecrets
+
+import (
+	"context"
+	"errors"
+	"path"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// roleEntry binds the objects a role is allowed to retrieve
+type roleEntry struct {
+	// The name of the connection used by the role
+	Connection string `json:"connection"`
+	// The Safes the role is allowed to retrieve objects from
+	Safes []string `json:"safes"`
+	// The glob patterns of the folders the role is allowed to retrieve
+	// objects from. If empty, only the root folder is allowed.
+	Folders []string `json:"folders"`
+	// The names of the objects the role is allowed to retrieve
+	Objects []string `json:"objects"`
+	// Query criteria used to retrieve the object
+	UserName string `json:"username"`
+	Address  string `json:"address"`
+	Database string `json:"database"`
+	PolicyID string `json:"policy_id"`
+	// The query format used when query criteria are set
+	QueryFormat string `json:"query_format"`
+	// The reason send to CCP when retrieving the password
+	Reason string `json:"reason"`
+	// The response fields the role is allowed to return, returned by default.
+	// If empty, all fields are allowed.
+	Fields []string `json:"fields"`
+}
+
+// hasQuery returns true if the role retrieves objects using query criteria
+func (r *roleEntry) hasQuery() bool {
+	return len(r.UserName) != 0 || len(r.Address) != 0 || len(r.Database) != 0 || len(r.PolicyID) != 0
+}
+
+// validate checks if the role binds at least one object
+func (r *roleEntry) validate() error {
+	if len(r.Connection) == 0 {
+		return errors.New("no connection provided")
+	}
+	if len(r.Safes) == 0 {
+		return errors.New("at least one safe must be provided")
+	}
+	if len(r.Objects) == 0 && !r.hasQuery() {
+		return errors.New("objects or query criteria must be provided")
+	}
+	for _, folder := range r.Folders {
+		if _, err := path.Match(folder, ""); err != nil {
+			return errors.New("invalid folder pattern: " + folder)
+		}
+	}
+	if _, err := parseQueryFormat(r.QueryFormat); err != nil {
+		return err
+	}
+	return validateFields(r.Fields)
+}
+
+// getRole returns the named role, or nil if the role does not exist.
+func getRole(ctx context.Context, s logical.Storage, name string) (*roleEntry, error) {
+	entry, err := s.Get(ctx, rolesPath+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	role := &roleEntry{}
+	if err := entry.DecodeJSON(role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// roleResponseFields are the fields of a role read
+var roleResponseFields = map[string]*framework.FieldSchema{
+	"connection": {
+		Type:        framework.TypeString,
+		Description: `The name of the connection to the CCP Web Service.`,
+	},
+	"safes": {
+		Type:        framework.TypeStringSlice,
+		Description: `The Safes the role is allowed to retrieve objects from.`,
+	},
+	"folders": {
+		Type:        framework.TypeStringSlice,
+		Description: `Glob patterns of the folders the role is allowed to retrieve objects from.`,
+	},
+	"objects": {
+		Type:        framework.TypeStringSlice,
+		Description: `The names of the objects the role is allowed to retrieve.`,
+	},
+	"username": {
+		Type:        framework.TypeString,
+		Description: `Search criteria according to the UserName account property.`,
+	},
+	"address": {
+		Type:        framework.TypeString,
+		Description: `Search criteria according to the Address account property.`,
+	},
+	"database": {
+		Type:        framework.TypeString,
+		Description: `Search criteria according to the Database account property.`,
+	},
+	"policy_id": {
+		Type:        framework.TypeString,
+		Description: `Search criteria according to the PolicyID account property.`,
+	},
+	"query_format": {
+		Type:        framework.TypeString,
+		Description: `The query format.`,
+	},
+	"reason": {
+		Type:        framework.TypeString,
+		Description: `The reason for retrieving the password.`,
+	},
+	"fields": {
+		Type:        framework.TypeStringSlice,
+		Description: `The response fields the role is allowed to return.`,
+	},
+}
+
+// pathRoles returns the path configurations for CRUD operations on the roles.
+func pathRoles(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: rolesPath + "/?$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixCCP,
+				OperationSuffix: "roles",
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback:  b.pathRolesList,
+					Responses: listResponses,
+				},
+			},
+
+			HelpSynopsis:    rolesListHelpSyn,
+			HelpDescription: rolesListHelpDesc,
+		},
+		{
+			Pattern: rolesPath + "/" + framework.GenericNameRegex("name"),
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixCCP,
+				OperationSuffix: "role",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: `The name of the role.`,
+					Required:    true,
+				},
+				"connection": {
+					Type:        framework.TypeString,
+					Description: `The name of the connection to the CCP Web Service.`,
+					Default:     defaultConnection,
+				},
+				"safes": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: `The Safes the role is allowed to retrieve objects from.`,
+				},
+				"folders": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: `Glob patterns of the folders the role is allowed to retrieve objects from. If empty, only the root folder is allowed.`,
+				},
+				"objects": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: `The names of the objects the role is allowed to retrieve.`,
+				},
+				"username": {
+					Type:        framework.TypeString,
+					Description: `Search criteria according to the UserName account property.`,
+				},
+				"address": {
+					Type:        framework.TypeString,
+					Description: `Search criteria according to the Address account property.`,
+				},
+				"database": {
+					Type:        framework.TypeString,
+					Description: `Search criteria according to the Database account property.`,
+				},
+				"policy_id": {
+					Type:        framework.TypeString,
+					Description: "The format that will be used in the setPolicyID method.",
+				},
+				"query_format": {
+					Type:        framework.TypeString,
+					Description: `Defines the query format, which can optionally use regular expressions. With regex, a Safe, folder or object selected by the client is matched literally, unless it is configured verbatim on the role.`,
+				},
+				"reason": {
+					Type:        framework.TypeString,
+					Description: `The reason for retrieving the password.`,
+				},
+				"fields": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: `The response fields the role is allowed to return, e.g. content,user_name. These are returned by default. If not set, all fields are allowed.`,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:  b.pathRolesWrite,
+					Responses: noContentResponses,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback:  b.pathRolesWrite,
+					Responses: noContentResponses,
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback:  b.pathRolesRead,
+					Responses: okResponses(roleResponseFields),
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback:  b.pathRolesDelete,
+					Responses: noContentResponses,
+				},
+			},
+			ExistenceCheck: b.pathRolesExists,
+
+			HelpSynopsis:    rolesHelpSyn,
+			HelpDescription: rolesHelpDesc,
+		},
+	}
+}
+
+// pathRolesList handles list commands to the roles
+func (b *backend) pathRolesList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List(ctx, rolesPath+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(names), nil
+}
+
+// pathRolesRead handles read commands to a role
+func (b *backend) pathRolesRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role, err := getRole(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"connection":   role.Connection,
+			"safes":        role.Safes,
+			"folders":      role.Folders,
+			"objects":      role.Objects,
+			"username":     role.UserName,
+			"address":      role.Address,
+			"database":     role.Database,
+			"policy_id":    role.PolicyID,
+			"query_format": role.QueryFormat,
+			"reason":       role.Reason,
+			"fields":       role.Fields,
+		},
+	}
+	return resp, nil
+}
+
+// pathRolesWrite handles create and update commands to a role
+func (b *backend) pathRolesWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	role := &roleEntry{
+		Connection:  data.Get("connection").(string),
+		Safes:       data.Get("safes").([]string),
+		Folders:     data.Get("folders").([]string),
+		Objects:     data.Get("objects").([]string),
+		UserName:    data.Get("username").(string),
+		Address:     data.Get("address").(string),
+		Database:    data.Get("database").(string),
+		PolicyID:    data.Get("policy_id").(string),
+		QueryFormat: data.Get("query_format").(string),
+		Reason:      data.Get("reason").(string),
+		Fields:      data.Get("fields").([]string),
+	}
+	if err := role.validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(rolesPath+"/"+name, role)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// pathRolesDelete handles delete commands to a role
+func (b *backend) pathRolesDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, rolesPath+"/"+data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRolesExists(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	role, err := getRole(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+
+	return role != nil, nil
+}
+
+const rolesListHelpSyn = `
+List the roles.
+`
+const rolesListHelpDesc = `
+This endpoint lists the names of the roles, which can be used to retrieve
+credentials using the creds endpoint.
+`
+
+const rolesHelpSyn = `
+Manage the roles binding the objects which can be retrieved.
+`
+const rolesHelpDesc = `
+This endpoint allows you to bind a set of Safes, folder patterns, object names
+or query criteria and a fixed reason to a role. Credentials are retrieved using
+the "creds/<role>" endpoint, allowing Vault policies to be written against role
+names instead of Safe and object paths.
+`
@@ -0,0 +1,100 @@
+package ccpsecrets
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// statusResponseFields are the fields of a status read
+var statusResponseFields = map[string]*framework.FieldSchema{
+	"state": {
+		Type:        framework.TypeString,
+		Description: `The state of the circuit breaker: closed, open or half_open.`,
+	},
+	"consecutive_failures": {
+		Type:        framework.TypeInt,
+		Description: `The number of consecutive transport failures.`,
+	},
+	"opened_at": {
+		Type:        framework.TypeTime,
+		Description: `The time the circuit breaker opened, if it is not closed.`,
+	},
+	"half_open_at": {
+		Type:        framework.TypeTime,
+		Description: `The time a probe request is passed, if the circuit breaker is not closed.`,
+	},
+	"healthy_hosts": {
+		Type:        framework.TypeStringSlice,
+		Description: `The hosts which are not backing off after a failure.`,
+	},
+}
+
+// pathStatus returns the path configuration for reading the status of a
+// connection
+func pathStatus(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: statusPath + "/" + framework.GenericNameRegex("name"),
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixCCP,
+			OperationSuffix: "connection-status",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `The name of the connection.`,
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:  b.pathStatusRead,
+				Responses: okResponses(statusResponseFields),
+			},
+		},
+
+		HelpSynopsis:    statusHelpSyn,
+		HelpDescription: statusHelpDesc,
+	}
+}
+
+// pathStatusRead handles read commands to the status of a connection
+func (b *backend) pathStatusRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	config, err := getConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	// A connection which has not been used yet has a closed breaker
+	now := time.Now()
+	status := newCircuitBreaker(config).status(now)
+	status["healthy_hosts"] = config.Hosts
+	b.lock.Lock()
+	if conn, ok := b.connections[name]; ok {
+		status = conn.breaker.status(now)
+		status["healthy_hosts"] = conn.healthyHosts()
+	}
+	b.lock.Unlock()
+
+	return &logical.Response{
+		Data: status,
+	}, nil
+}
+
+const statusHelpSyn = `
+Read the status of a connection.
+`
+const statusHelpDesc = `
+This endpoint returns the state of the circuit breaker and the healthy hosts
+of a connection. After breaker_threshold consecutive transport failures the
+breaker opens and requests fail without contacting the CCP Web Service, or are
+served from the cache. After breaker_timeout seconds the breaker is half open
+and a single probe request is passed. The breaker closes if the probe
+succeeds.
+`
@@ -0,0 +1,228 @@
+package ccpsecrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// templateEntry is a Go text/template rendering the response data of a
+// request
+type templateEntry struct {
+	Template string `json:"template"`
+}
+
+// templateFuncs are the helpers available to templates, in addition to the
+// text/template builtins such as urlquery
+var templateFuncs = template.FuncMap{
+	"base64": func(s interface{}) string {
+		return base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(s)))
+	},
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// parseTemplate parses the template text. Referencing a field missing in the
+// response data is an error.
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// getTemplate returns the named template, or nil if the template does not
+// exist.
+func getTemplate(ctx context.Context, s logical.Storage, name string) (*template.Template, error) {
+	entry, err := s.Get(ctx, templatesPath+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	t := &templateEntry{}
+	if err := entry.DecodeJSON(t); err != nil {
+		return nil, err
+	}
+	return parseTemplate(name, t.Template)
+}
+
+// formatTemplate returns the template selected by the format of a request, or
+// nil if no format is selected.
+func formatTemplate(ctx context.Context, s logical.Storage, format string) (*template.Template, error) {
+	if len(format) == 0 {
+		return nil, nil
+	}
+	t, err := getTemplate(ctx, s, format)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("unknown template: %s", format)
+	}
+	return t, nil
+}
+
+// render executes the template using the response data
+func render(t *template.Template, data map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("unable to render template %s: %w", t.Name(), err)
+	}
+	return sb.String(), nil
+}
+
+// templateResponseFields are the fields of a template read
+var templateResponseFields = map[string]*framework.FieldSchema{
+	"template": {
+		Type:        framework.TypeString,
+		Description: `The Go text/template rendering the response fields.`,
+	},
+}
+
+// pathTemplates returns the path configurations for CRUD operations on the
+// templates.
+func pathTemplates(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: templatesPath + "/?$",
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixCCP,
+				OperationSuffix: "templates",
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback:  b.pathTemplatesList,
+					Responses: listResponses,
+				},
+			},
+
+			HelpSynopsis:    templatesListHelpSyn,
+			HelpDescription: templatesListHelpDesc,
+		},
+		{
+			Pattern: templatesPath + "/" + framework.GenericNameRegex("name"),
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixCCP,
+				OperationSuffix: "template",
+			},
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: `The name of the template.`,
+					Required:    true,
+				},
+				"template": {
+					Type:        framework.TypeString,
+					Description: `The Go text/template rendering the response fields, e.g. {{.user_name}}:{{.content | urlquery}}.`,
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:  b.pathTemplatesWrite,
+					Responses: noContentResponses,
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback:  b.pathTemplatesRead,
+					Responses: okResponses(templateResponseFields),
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback:  b.pathTemplatesDelete,
+					Responses: noContentResponses,
+				},
+			},
+
+			HelpSynopsis:    templatesHelpSyn,
+			HelpDescription: templatesHelpDesc,
+		},
+	}
+}
+
+// pathTemplatesList handles list commands to the templates
+func (b *backend) pathTemplatesList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List(ctx, templatesPath+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(names), nil
+}
+
+// pathTemplatesRead handles read commands to a template
+func (b *backend) pathTemplatesRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entry, err := req.Storage.Get(ctx, templatesPath+"/"+data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	t := &templateEntry{}
+	if err := entry.DecodeJSON(t); err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"template": t.Template,
+		},
+	}, nil
+}
+
+// pathTemplatesWrite handles update commands to a template
+func (b *backend) pathTemplatesWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	text := data.Get("template").(string)
+	if len(text) == 0 {
+		return logical.ErrorResponse("no template provided"), nil
+	}
+	if _, err := parseTemplate(name, text); err != nil {
+		return logical.ErrorResponse("invalid template: %v", err), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(templatesPath+"/"+name, &templateEntry{Template: text})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// pathTemplatesDelete handles delete commands to a template
+func (b *backend) pathTemplatesDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, templatesPath+"/"+data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const templatesListHelpSyn = `
+List the templates.
+`
+const templatesListHelpDesc = `
+This endpoint lists the names of the templates, which can be selected using
+the format parameter of the object, query and creds endpoints.
+`
+
+const templatesHelpSyn = `
+Manage the templates rendering retrieved credentials.
+`
+const templatesHelpDesc = `
+A template is a Go text/template rendering the response fields of a request,
+e.g. into a connection string or a config file. Requests selecting the
+template using the format parameter return the result in the rendered field.
+Besides the text/template builtins such as urlquery, the base64 and json
+helpers are available. Referencing a missing field fails the request.
+`
@@ -0,0 +1,239 @@
+package ccpsecrets
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+)
+
+// propertiesField contains the custom account properties of a response
+const propertiesField = "properties"
+
+// credentialResponseFields declares the standard fields of a CCP response. The
+// field types are used to normalize the string values returned by CCP.
+var credentialResponseFields = map[string]*framework.FieldSchema{
+	"content": {
+		Type:        framework.TypeString,
+		Description: `The password or secret of the account.`,
+	},
+	"user_name": {
+		Type:        framework.TypeString,
+		Description: `The user name of the account.`,
+	},
+	"address": {
+		Type:        framework.TypeString,
+		Description: `The address of the account.`,
+	},
+	"database": {
+		Type:        framework.TypeString,
+		Description: `The database of the account.`,
+	},
+	"port": {
+		Type:        framework.TypeString,
+		Description: `The port of the account.`,
+	},
+	"logon_domain": {
+		Type:        framework.TypeString,
+		Description: `The logon domain of the account.`,
+	},
+	"safe": {
+		Type:        framework.TypeString,
+		Description: `The Safe of the account.`,
+	},
+	"folder": {
+		Type:        framework.TypeString,
+		Description: `The folder of the account.`,
+	},
+	"name": {
+		Type:        framework.TypeString,
+		Description: `The object name of the account.`,
+	},
+	"policy_id": {
+		Type:        framework.TypeString,
+		Description: `The platform of the account.`,
+	},
+	"device_type": {
+		Type:        framework.TypeString,
+		Description: `The device type of the platform of the account.`,
+	},
+	"creation_method": {
+		Type:        framework.TypeString,
+		Description: `How the account was created.`,
+	},
+	"cpm_status": {
+		Type:        framework.TypeString,
+		Description: `The status of the last CPM task of the account.`,
+	},
+	"last_task": {
+		Type:        framework.TypeString,
+		Description: `The last CPM task of the account.`,
+	},
+	"retries_count": {
+		Type:        framework.TypeInt,
+		Description: `The number of retries of the last CPM task.`,
+	},
+	"cpm_disabled": {
+		Type:        framework.TypeBool,
+		Description: `Whether automatic management by the CPM is disabled.`,
+	},
+	"password_change_in_process": {
+		Type:        framework.TypeBool,
+		Description: `Whether the password is being changed.`,
+	},
+	"last_success_change": {
+		Type:        framework.TypeTime,
+		Description: `The time of the last successful password change.`,
+	},
+	"last_success_verification": {
+		Type:        framework.TypeTime,
+		Description: `The time of the last successful password verification.`,
+	},
+	"last_success_reconciliation": {
+		Type:        framework.TypeTime,
+		Description: `The time of the last successful password reconciliation.`,
+	},
+	propertiesField: {
+		Type:        framework.TypeMap,
+		Description: `The custom properties of the account.`,
+	},
+}
+
+// credentialResponses declares the responses of a credential request. A CCP
+// logical error contains its CyberArk error code in the ccp_error_code field.
+var credentialResponses = map[int][]framework.Response{
+	http.StatusOK: {{
+		Description: http.StatusText(http.StatusOK),
+		Fields:      credentialResponseFields,
+	}},
+	http.StatusNotFound: {{
+		Description: `The object was not found in CCP.`,
+	}},
+	http.StatusForbidden: {{
+		Description: `The application is not authorized to retrieve the object.`,
+	}},
+	http.StatusConflict: {{
+		Description: `The password is being changed.`,
+	}},
+	http.StatusBadGateway: {{
+		Description: `The CCP Web Service is unavailable.`,
+	}},
+	http.StatusServiceUnavailable: {{
+		Description: `The circuit breaker of the connection is open.`,
+	}},
+}
+
+// noContentResponses declares the response of an operation returning no data
+var noContentResponses = map[int][]framework.Response{
+	http.StatusNoContent: {{
+		Description: http.StatusText(http.StatusNoContent),
+	}},
+}
+
+// listResponses declares the response of a list operation
+var listResponses = okResponses(map[string]*framework.FieldSchema{
+	"keys": {
+		Type:        framework.TypeStringSlice,
+		Description: `The listed keys.`,
+	},
+})
+
+// okResponses declares the response of an operation returning the fields
+func okResponses(fields map[string]*framework.FieldSchema) map[int][]framework.Response {
+	return map[int][]framework.Response{
+		http.StatusOK: {{
+			Description: http.StatusText(http.StatusOK),
+			Fields:      fields,
+		}},
+	}
+}
+
+// normalizeResponse converts the snake case map of a CCP response to the
+// response schema. Standard fields are converted to their declared type and
+// custom properties are moved to the properties map. A standard field which
+// can not be converted is dropped, so a field is never returned with a type
+// other than declared.
+func normalizeResponse(data map[string]interface{}) map[string]interface{} {
+	normalized := make(map[string]interface{}, len(data))
+	properties := make(map[string]interface{})
+	for key, value := range data {
+		schema, ok := credentialResponseFields[key]
+		switch {
+		case key == propertiesField:
+			if m, ok := value.(map[string]interface{}); ok {
+				for k, v := range m {
+					properties[k] = v
+				}
+				continue
+			}
+			properties[key] = value
+		case !ok:
+			properties[key] = value
+		default:
+			if v, ok := normalizeValue(schema.Type, value); ok {
+				normalized[key] = v
+			}
+		}
+	}
+	normalized[propertiesField] = properties
+	return normalized
+}
+
+// normalizeValue converts a value to the field type. Besides the strings
+// returned by CCP, the values decoded from a stored JSON response are
+// converted. It returns false if the value can not be converted.
+func normalizeValue(t framework.FieldType, value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		value = v.String()
+	case float64:
+		if t == framework.TypeInt && v == math.Trunc(v) {
+			return int(v), true
+		}
+	}
+
+	switch t {
+	case framework.TypeString:
+		s, ok := value.(string)
+		return s, ok
+	case framework.TypeMap:
+		m, ok := value.(map[string]interface{})
+		return m, ok
+	case framework.TypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			return b, err == nil
+		}
+	case framework.TypeInt:
+		switch v := value.(type) {
+		case int:
+			return v, true
+		case string:
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			return n, err == nil
+		}
+	case framework.TypeTime:
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			return "", true
+		}
+		if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC().Format(time.RFC3339), true
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t.UTC().Format(time.RFC3339), true
+		}
+	}
+	return nil, false
+}
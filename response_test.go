@@ -0,0 +1,57 @@
+package ccpsecrets
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+)
+
+func TestNormalizeResponse(t *testing.T) {
+	got := normalizeResponse(map[string]interface{}{
+		"content":                    "secret",
+		"user_name":                  "admin",
+		"password_change_in_process": "False",
+		"retries_count":              "-1",
+		"last_success_change":        "1633964581",
+		"cpm_disabled":               "unknown",
+		"cpm_status":                 nil,
+		"owner":                      "team-a",
+	})
+	want := map[string]interface{}{
+		"content":                    "secret",
+		"user_name":                  "admin",
+		"password_change_in_process": false,
+		"retries_count":              -1,
+		"last_success_change":        "2021-10-11T15:03:01Z",
+		"properties": map[string]interface{}{
+			"owner": "team-a",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v: want %v", got, want)
+	}
+
+	// Normalizing a normalized response, e.g. a cached response, is a no-op
+	if again := normalizeResponse(got); !reflect.DeepEqual(again, want) {
+		t.Fatalf("got %v: want %v", again, want)
+	}
+
+	// A stored response is decoded with JSON numbers
+	stored, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := make(map[string]interface{})
+	if err := jsonutil.DecodeJSON(stored, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if again := normalizeResponse(decoded); !reflect.DeepEqual(again, want) {
+		t.Fatalf("got %v: want %v", again, want)
+	}
+	if n, ok := normalizeValue(framework.TypeInt, float64(3)); !ok || n != 3 {
+		t.Fatalf("got %v, %v: want 3", n, ok)
+	}
+}
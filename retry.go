@@ -0,0 +1,103 @@
+package ccpsecrets
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// retryPolicy retries credential requests failing with a transport error or
+// a retryable CCP error code, with exponential back-off between attempts.
+type retryPolicy struct {
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	jitter     bool
+	errorCodes []string
+}
+
+// retryPolicy returns the retry policy of the config
+func (c *clientConfig) retryPolicy() *retryPolicy {
+	return &retryPolicy{
+		maxRetries: c.MaxRetries,
+		minBackoff: time.Duration(c.MinBackoff) * time.Second,
+		maxBackoff: time.Duration(c.MaxBackoff) * time.Second,
+		jitter:     c.Jitter,
+		errorCodes: c.RetryErrorCodes,
+	}
+}
+
+// validateRetry checks the retry settings of the config
+func (c *clientConfig) validateRetry() error {
+	if c.MaxRetries < 0 || c.MinBackoff < 0 || c.MaxBackoff < 0 {
+		return errors.New("max_retries, min_backoff and max_backoff must be positive")
+	}
+	if c.MinBackoff > c.MaxBackoff {
+		return errors.New("min_backoff must not be greater than max_backoff")
+	}
+	for _, code := range c.RetryErrorCodes {
+		if ccpErrorCode(code) != code {
+			return errors.New("invalid retry_error_codes: use CyberArk error codes, e.g. APPAP007E")
+		}
+	}
+	return nil
+}
+
+// backoff returns the back-off before the retry. The back-off doubles with
+// every retry, up to the maximum back-off. With jitter, a random back-off
+// between half and the full back-off is used.
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	backoff := p.minBackoff
+	for i := 1; i < retry && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.maxBackoff {
+		backoff = p.maxBackoff
+	}
+	if p.jitter && backoff > 1 {
+		backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	}
+	return backoff
+}
+
+// retryable returns true if the outcome of an attempt should be retried. Only
+// transport errors are retried, a request rejected by the circuit breaker or
+// failing to decode the response is not.
+func (p *retryPolicy) retryable(ctx context.Context, cresp *credentialResponse, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, errBreakerOpen) {
+		return false
+	}
+	if err != nil {
+		return transportError(err)
+	}
+	return len(cresp.LogicalError) != 0 && slices.Contains(p.errorCodes, ccpErrorCode(cresp.LogicalError))
+}
+
+// do executes the attempt until it succeeds, fails with an error which is not
+// retryable or the retries are exhausted. A retry is not attempted, if its
+// back-off ends after the deadline of the context. The number of retries is
+// returned with the outcome of the last attempt.
+func (p *retryPolicy) do(ctx context.Context, attempt func() (*credentialResponse, error)) (*credentialResponse, int, error) {
+	retries := 0
+	for {
+		cresp, err := attempt()
+		if retries == p.maxRetries || !p.retryable(ctx, cresp, err) {
+			return cresp, retries, err
+		}
+
+		backoff := p.backoff(retries + 1)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+			return cresp, retries, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return cresp, retries, err
+		case <-timer.C:
+		}
+		retries++
+	}
+}
@@ -0,0 +1,122 @@
+package ccpsecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := &retryPolicy{minBackoff: time.Second, maxBackoff: 5 * time.Second}
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		if got := p.backoff(retry); got != want {
+			t.Errorf("retry %d: got %v, want %v", retry, got, want)
+		}
+	}
+
+	p.jitter = true
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(3); got < 2*time.Second || got > 4*time.Second {
+			t.Fatalf("got %v: want a back-off between 2s and 4s", got)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := &retryPolicy{maxRetries: 2, errorCodes: []string{"APPAP007E"}}
+	ctx := context.Background()
+
+	// Transport errors are retried until the retries are exhausted
+	attempts := 0
+	_, retries, err := p.do(ctx, func() (*credentialResponse, error) {
+		attempts++
+		return nil, fmt.Errorf("%w: ccp1: connection reset by peer", errCCPUnavailable)
+	})
+	if err == nil || attempts != 3 || retries != 2 {
+		t.Fatalf("got %d attempts, %d retries, %v: want 3 attempts and an error", attempts, retries, err)
+	}
+
+	// Retryable error codes are retried, other logical errors are not
+	for logicalError, want := range map[string]int{
+		"APPAP007E Connection to the Vault has failed":           3,
+		"APPAP004E Password object matching query was not found": 1,
+	} {
+		attempts = 0
+		cresp, _, err := p.do(ctx, func() (*credentialResponse, error) {
+			attempts++
+			return &credentialResponse{LogicalError: logicalError}, nil
+		})
+		if err != nil || cresp.LogicalError != logicalError || attempts != want {
+			t.Errorf("%s: got %d attempts, want %d", logicalError, attempts, want)
+		}
+	}
+
+	// Errors other than transport errors are not retried
+	for _, err := range []error{
+		fmt.Errorf("%w: unexpected end of JSON input", errInvalidResponse),
+		errors.Join(errors.New("invalid template"), errors.New("unknown field")),
+	} {
+		attempts = 0
+		_, _, got := p.do(ctx, func() (*credentialResponse, error) {
+			attempts++
+			return nil, err
+		})
+		if got == nil || attempts != 1 {
+			t.Errorf("%v: got %d attempts, want 1", err, attempts)
+		}
+	}
+
+	// Network errors are retried
+	attempts = 0
+	_, _, err = p.do(ctx, func() (*credentialResponse, error) {
+		attempts++
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	})
+	if err == nil || attempts != 3 {
+		t.Fatalf("got %d attempts: want 3", attempts)
+	}
+
+	// A successful retry ends the retries
+	attempts = 0
+	cresp, retries, err := p.do(ctx, func() (*credentialResponse, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, fmt.Errorf("%w: ccp1: 502 Bad Gateway", errCCPUnavailable)
+		}
+		return &credentialResponse{Data: map[string]interface{}{"content": "secret"}}, nil
+	})
+	if err != nil || retries != 1 || cresp.Data["content"] != "secret" {
+		t.Fatalf("got %d retries, %v: want 1 retry and the response", retries, err)
+	}
+
+	// No retry is attempted when the back-off ends after the deadline
+	p.minBackoff, p.maxBackoff = time.Minute, time.Minute
+	deadline, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	attempts = 0
+	_, _, err = p.do(deadline, func() (*credentialResponse, error) {
+		attempts++
+		return nil, fmt.Errorf("%w: ccp1: connection reset by peer", errCCPUnavailable)
+	})
+	if err == nil || attempts != 1 {
+		t.Fatalf("got %d attempts: want 1", attempts)
+	}
+}
+
+func TestValidateRetry(t *testing.T) {
+	for _, c := range []*clientConfig{
+		{MaxRetries: -1},
+		{MinBackoff: 10, MaxBackoff: 1},
+		{RetryErrorCodes: []string{"timeout"}},
+	} {
+		if err := c.validateRetry(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+	if err := (&clientConfig{MaxRetries: 3, MinBackoff: 1, MaxBackoff: 10, RetryErrorCodes: []string{"APPAP007E"}}).validateRetry(); err != nil {
+		t.Fatal(err)
+	}
+}
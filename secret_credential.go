@@ -0,0 +1,140 @@
+package ccpsecrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const secretCredentialType = "ccp_credential"
+
+// secretCredential defines the lease of a credential retrieved from CCP
+func secretCredential(b *backend) *framework.Secret {
+	return &framework.Secret{
+		Type: secretCredentialType,
+		Fields: map[string]*framework.FieldSchema{
+			"content": {
+				Type:        framework.TypeString,
+				Description: `The password or secret retrieved from CCP.`,
+			},
+			"user_name": {
+				Type:        framework.TypeString,
+				Description: `The user name of the account.`,
+			},
+		},
+
+		Renew:  b.secretCredentialRenew,
+		Revoke: b.secretCredentialRevoke,
+	}
+}
+
+// leaseResponse converts the credential response to a logical.Response. A
+// successful response is issued as a lease, which contains the request for
+// renewal. Only the requested fields are returned. A logical error is
+// returned with the HTTP status mapped from its CyberArk error code.
+func (b *backend) leaseResponse(ctx context.Context, req *logical.Request, cr *credentialRequest, cresp *credentialResponse) (*logical.Response, error) {
+	if len(cresp.LogicalError) != 0 {
+		return logicalErrorResponse(req, cresp.LogicalError, cresp.Warnings)
+	}
+	data, err := cr.responseData(cresp.Data)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	request, err := json.Marshal(cr)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := b.Salt(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := b.Secret(secretCredentialType).Response(data, map[string]interface{}{
+		"connection":   cr.Connection,
+		"safe":         cr.Request.Safe,
+		"folder":       cr.Request.Folder,
+		"object":       cr.Request.Object,
+		"request":      string(request),
+		"content_hmac": contentHMAC(salt, cresp.Data),
+	})
+	resp.Secret.TTL = cresp.TTL
+	resp.Secret.MaxTTL = cresp.MaxTTL
+	resp.Warnings = cresp.Warnings
+	return resp, nil
+}
+
+// secretCredentialRenew re-queries CCP for the credential of the lease and
+// reports whether the content has changed since the lease was issued.
+func (b *backend) secretCredentialRenew(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	request, ok := req.Secret.InternalData["request"].(string)
+	if !ok {
+		return nil, errors.New("internal data of the lease does not contain the request")
+	}
+	cr := &credentialRequest{}
+	if err := json.Unmarshal([]byte(request), cr); err != nil {
+		return nil, err
+	}
+
+	conn, err := b.Connection(ctx, req.Storage, cr.Connection)
+	if err != nil {
+		return retrieveErrorResponse(err)
+	}
+	cresp, err := b.request(ctx, conn, cr)
+	if err != nil {
+		return retrieveErrorResponse(err)
+	}
+	if len(cresp.LogicalError) != 0 {
+		return logical.ErrorResponse(cresp.LogicalError), nil
+	}
+	salt, err := b.Salt(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// The increment of the renewal is applied by the expiration manager,
+	// limited to the TTLs of the connection
+	ttl := time.Duration(conn.config.TTL) * time.Second
+	maxTTL := time.Duration(conn.config.MaxTTL) * time.Second
+	resp, err := framework.LeaseExtend(ttl, maxTTL, b.System())(ctx, req, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentChanged(resp.Secret, contentHMAC(salt, cresp.Data), time.Now()) {
+		resp.AddWarning(fmt.Sprintf("the content of %s has changed in CCP since %s, read the secret again", cr.Request.Object, resp.Secret.InternalData["content_changed"]))
+	}
+	return resp, nil
+}
+
+// contentChanged compares the content HMAC to the HMAC taken when the lease
+// was issued, which is never replaced. The time the change was first detected
+// is kept in the lease until the content matches again.
+func contentChanged(secret *logical.Secret, hmac string, now time.Time) bool {
+	if hmac == secret.InternalData["content_hmac"] {
+		delete(secret.InternalData, "content_changed")
+		return false
+	}
+	if _, ok := secret.InternalData["content_changed"]; !ok {
+		secret.InternalData["content_changed"] = now.UTC().Format(time.RFC3339)
+	}
+	return true
+}
+
+// secretCredentialRevoke revokes the lease. The credential is owned by
+// CyberArk, so there is nothing to revoke in CCP.
+func (b *backend) secretCredentialRevoke(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return nil, nil
+}
+
+// contentHMAC returns the HMAC of the content of the response data, keyed by
+// the salt of the mount, so the lease does not allow guessing the content
+func contentHMAC(salt *salt.Salt, data map[string]interface{}) string {
+	return salt.GetHMAC(fmt.Sprint(data["content"]))
+}
@@ -0,0 +1,43 @@
+package ccpsecrets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestContentChanged(t *testing.T) {
+	salt, err := salt.NewSalt(context.Background(), &logical.InmemStorage{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued := contentHMAC(salt, map[string]interface{}{"content": "old"})
+	rotated := contentHMAC(salt, map[string]interface{}{"content": "new"})
+	if sum := sha256.Sum256([]byte("old")); issued == hex.EncodeToString(sum[:]) {
+		t.Fatal("expected the content to be keyed by the salt")
+	}
+	secret := &logical.Secret{InternalData: map[string]interface{}{"content_hmac": issued}}
+	now := time.Now()
+
+	if contentChanged(secret, issued, now) {
+		t.Fatal("expected the content to be unchanged")
+	}
+
+	// Every renewal warns while the content differs from the issued content
+	for i := 0; i < 3; i++ {
+		if !contentChanged(secret, rotated, now.Add(time.Duration(i)*time.Hour)) {
+			t.Fatalf("renewal %d: expected the content to be changed", i)
+		}
+	}
+	if secret.InternalData["content_hmac"] != issued {
+		t.Fatal("expected the issued content HMAC to be kept")
+	}
+	if got, want := secret.InternalData["content_changed"], now.UTC().Format(time.RFC3339); got != want {
+		t.Fatalf("got content_changed %v: want the first detection %s", got, want)
+	}
+}